@@ -1,11 +1,10 @@
 package main
 
 import (
-	"bytes"
-	"fmt"
-	"io"
 	"log"
 	"net/http"
+	"net/http/httputil"
+	"sync/atomic"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -13,13 +12,19 @@ import (
 	"go.uber.org/zap"
 
 	"news-aggregator/pkg/config"
+	"news-aggregator/pkg/jwks"
+	"news-aggregator/pkg/metrics"
 	"news-aggregator/pkg/middleware"
+	"news-aggregator/pkg/ratelimit"
 )
 
 type APIGateway struct {
 	config *config.Config
 	redis  *redis.Client
 	logger *zap.Logger
+	auth   *middleware.AuthMiddleware
+	routes atomic.Pointer[routeTable]
+	proxy  *httputil.ReverseProxy
 }
 
 func main() {
@@ -34,124 +39,62 @@ func main() {
 		Addr: cfg.RedisURL,
 	})
 
-	gateway := &APIGateway{
-		config: cfg,
-		redis:  rdb,
-		logger: logger,
+	if err := metrics.RegisterRedis(rdb); err != nil {
+		logger.Error("Failed to register Redis metrics", zap.Error(err))
 	}
 
 	router := gin.Default()
+	// The gateway faces clients directly; never take their word for their IP
+	router.SetTrustedProxies(nil)
 
 	// Middleware
 	router.Use(middleware.Logger())
+	router.Use(metrics.Middleware())
 	router.Use(middleware.CORS())
 
-	auth := middleware.NewAuthMiddleware(cfg.JWTSecret, rdb)
-	router.Use(auth.RateLimit(cfg.RateLimitReqs, time.Duration(cfg.RateLimitWindow)*time.Second))
-
-	gateway.setupRoutes(router, auth)
-
-	logger.Info("API Gateway starting", zap.String("port", cfg.APIGatewayPort))
-	log.Fatal(http.ListenAndServe(":"+cfg.APIGatewayPort, router))
-}
-
-func (g *APIGateway) setupRoutes(router *gin.Engine, auth *middleware.AuthMiddleware) {
-	api := router.Group("/api/v1")
-	{
-		// Auth routes
-		authGroup := api.Group("/auth")
-		{
-			authGroup.POST("/register", g.proxyToAuth)
-			authGroup.POST("/login", g.proxyToAuth)
-			authGroup.POST("/verify", g.proxyToAuth)
-		}
-
-		// News routes
-		newsGroup := api.Group("/news")
-		{
-			newsGroup.GET("", g.proxyToNewsAPI)
-			newsGroup.GET("/:id", g.proxyToNewsAPI)
-			newsGroup.GET("/source/:source", g.proxyToNewsAPI)
-
-			// Protected routes
-			protected := newsGroup.Group("")
-			protected.Use(auth.JWTAuth())
-			{
-				protected.POST("/favorite/:id", g.proxyToNewsAPI)
-			}
-		}
+	keys := jwks.NewClient(cfg.JWKSURL, time.Duration(cfg.JWKSCacheMinutes)*time.Minute)
+	auth := middleware.NewAuthMiddleware(keys.Keyfunc, rdb)
+	tiers, err := ratelimit.ParseTiers(cfg.RateLimitReqs, time.Duration(cfg.RateLimitWindow)*time.Second, cfg.RateLimitTiers)
+	if err != nil {
+		logger.Fatal("Invalid rate limit tiers", zap.Error(err))
 	}
+	router.Use(auth.RateLimit(middleware.RateLimitOptions{Tiers: tiers, FailOpen: cfg.RateLimitFailOpen}))
 
-	// Health check
-	router.GET("/health", g.healthCheck)
-	router.GET("/", g.welcome)
-}
-
-func (g *APIGateway) proxyToAuth(c *gin.Context) {
-	targetURL := fmt.Sprintf("http://localhost:%s%s", g.config.AuthServicePort, c.Request.RequestURI)
-	g.proxyRequest(c, targetURL)
-}
-
-func (g *APIGateway) proxyToNewsAPI(c *gin.Context) {
-	targetURL := fmt.Sprintf("http://localhost:%s%s", g.config.NewsAPIPort, c.Request.RequestURI)
-	g.proxyRequest(c, targetURL)
-}
-
-func (g *APIGateway) proxyRequest(c *gin.Context, targetURL string) {
-	// Read request body
-	var body []byte
-	if c.Request.Body != nil {
-		body, _ = io.ReadAll(c.Request.Body)
-		c.Request.Body.Close()
+	gateway := &APIGateway{
+		config: cfg,
+		redis:  rdb,
+		logger: logger,
+		auth:   auth,
 	}
+	gateway.proxy = gateway.newReverseProxy()
 
-	// Create new request
-	req, err := http.NewRequest(c.Request.Method, targetURL, bytes.NewBuffer(body))
+	table, err := gateway.loadRoutes(cfg.GatewayRoutesFile)
 	if err != nil {
-		g.logger.Error("Failed to create proxy request", zap.Error(err))
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Proxy request failed"})
-		return
+		logger.Fatal("Failed to load routes", zap.String("file", cfg.GatewayRoutesFile), zap.Error(err))
 	}
-
-	// Copy headers
-	for key, values := range c.Request.Header {
-		for _, value := range values {
-			req.Header.Add(key, value)
-		}
+	gateway.useRoutes(table)
+	if cfg.GatewayRoutesFile != "" && cfg.GatewayRoutesReloadSeconds > 0 {
+		go gateway.watchRoutes(cfg.GatewayRoutesFile, time.Duration(cfg.GatewayRoutesReloadSeconds)*time.Second)
 	}
 
-	// Copy query parameters
-	req.URL.RawQuery = c.Request.URL.RawQuery
+	gateway.setupRoutes(router)
 
-	// Make request
-	client := &http.Client{Timeout: 30 * time.Second}
-	resp, err := client.Do(req)
-	if err != nil {
-		g.logger.Error("Proxy request failed", zap.String("url", targetURL), zap.Error(err))
-		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Service unavailable"})
-		return
-	}
-	defer resp.Body.Close()
-
-	// Read response
-	responseBody, err := io.ReadAll(resp.Body)
-	if err != nil {
-		g.logger.Error("Failed to read proxy response", zap.Error(err))
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read response"})
-		return
-	}
+	logger.Info("API Gateway starting", zap.String("port", cfg.APIGatewayPort))
+	log.Fatal(http.ListenAndServe(":"+cfg.APIGatewayPort, router))
+}
 
-	// Copy response headers
-	for key, values := range resp.Header {
-		for _, value := range values {
-			c.Header(key, value)
-		}
-	}
+func (g *APIGateway) setupRoutes(router *gin.Engine) {
+	// Everything else goes through the route table
+	router.NoRoute(g.dispatch)
 
-	// Return response
-	c.Data(resp.StatusCode, resp.Header.Get("Content-Type"), responseBody)
+	// Health check
+	router.GET("/health", g.healthCheck)
+	router.GET("/metrics", gin.WrapH(metrics.Handler()))
+	router.GET("/", g.welcome)
 }
 
+// healthCheck reports what the active health checks and circuit breakers
+// last saw rather than probing every instance on each call.
 func (g *APIGateway) healthCheck(c *gin.Context) {
 	status := gin.H{
 		"status":    "healthy",
@@ -159,15 +102,12 @@ func (g *APIGateway) healthCheck(c *gin.Context) {
 		"services":  gin.H{},
 	}
 
-	// Check auth service
-	authHealth := g.checkServiceHealth(fmt.Sprintf("http://localhost:%s/health", g.config.AuthServicePort))
-	status["services"].(gin.H)["auth"] = authHealth
-
-	// Check news API service
-	newsAPIHealth := g.checkServiceHealth(fmt.Sprintf("http://localhost:%s/health", g.config.NewsAPIPort))
-	status["services"].(gin.H)["news-api"] = newsAPIHealth
-
-	overallHealthy := authHealth["status"] == "healthy" && newsAPIHealth["status"] == "healthy"
+	overallHealthy := true
+	for name, u := range g.routes.Load().upstreams {
+		health := u.status()
+		status["services"].(gin.H)[name] = health
+		overallHealthy = overallHealthy && health["status"] == "healthy"
+	}
 
 	statusCode := http.StatusOK
 	if !overallHealthy {
@@ -178,36 +118,55 @@ func (g *APIGateway) healthCheck(c *gin.Context) {
 	c.JSON(statusCode, status)
 }
 
-func (g *APIGateway) checkServiceHealth(url string) gin.H {
-	client := &http.Client{Timeout: 5 * time.Second}
-	resp, err := client.Get(url)
-	if err != nil {
-		return gin.H{"status": "unhealthy", "error": err.Error()}
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode == http.StatusOK {
-		return gin.H{"status": "healthy"}
-	}
-
-	return gin.H{"status": "unhealthy", "http_status": resp.StatusCode}
-}
-
 func (g *APIGateway) welcome(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{
 		"message": "News Aggregator API Gateway",
 		"version": "1.0.0",
 		"endpoints": gin.H{
 			"auth": gin.H{
-				"register": "POST /api/v1/auth/register",
-				"login":    "POST /api/v1/auth/login",
-				"verify":   "POST /api/v1/auth/verify",
+				"register":        "POST /api/v1/auth/register",
+				"login":           "POST /api/v1/auth/login",
+				"login_mfa":       "POST /api/v1/auth/login/mfa",
+				"verify":          "POST /api/v1/auth/verify",
+				"refresh":         "POST /api/v1/auth/refresh",
+				"logout":          "POST /api/v1/auth/logout (auth required)",
+				"logout_all":      "POST /api/v1/auth/logout-all (auth required)",
+				"forgot_password": "POST /api/v1/auth/forgot-password",
+				"reset_password":  "POST /api/v1/auth/reset-password",
+				"verify_email":    "POST /api/v1/auth/verify-email",
+				"resend_verify":   "POST /api/v1/auth/verify-email/resend (auth required)",
+				"totp":            "POST /api/v1/auth/mfa/totp/enroll|confirm|disable (auth required)",
+				"recovery_codes":  "POST /api/v1/auth/mfa/recovery-codes (auth required)",
+				"api_keys":        "POST, GET /api/v1/auth/api-keys, DELETE /api/v1/auth/api-keys/:id (auth required)",
+				"sso_providers":   "GET /api/v1/auth/oidc/providers",
+				"sso_login":       "GET /api/v1/auth/oidc/:provider/login?return_to=",
 			},
 			"news": gin.H{
 				"list":      "GET /api/v1/news",
+				"search":    "GET /api/v1/news/search?q=",
 				"get":       "GET /api/v1/news/:id",
 				"by_source": "GET /api/v1/news/source/:source",
-				"favorite":  "POST /api/v1/news/favorite/:id (auth required)",
+				"favorite":  "POST, DELETE, GET /api/v1/news/favorite/:id (auth required)",
+				"delete":    "DELETE /api/v1/news/:id (news:moderate)",
+			},
+			"me": gin.H{
+				"profile":   "GET, PATCH, DELETE /api/v1/me (auth required)",
+				"password":  "POST /api/v1/me/password (auth required)",
+				"export":    "GET /api/v1/me/export (auth required)",
+				"sessions":  "GET /api/v1/me/sessions, DELETE /api/v1/me/sessions/:id (auth required)",
+				"favorites": "GET /api/v1/me/favorites (auth required)",
+			},
+			"admin": gin.H{
+				"sources":         "GET, POST /api/v1/admin/sources (sources:manage)",
+				"source":          "GET, PUT, DELETE /api/v1/admin/sources/:id (sources:manage)",
+				"enable_disable":  "POST /api/v1/admin/sources/:id/enable|disable (sources:manage)",
+				"users":           "GET /api/v1/admin/users (users:admin)",
+				"user":            "GET /api/v1/admin/users/:id (users:admin)",
+				"role":            "PUT /api/v1/admin/users/:id/role (users:admin)",
+				"suspend_restore": "POST /api/v1/admin/users/:id/suspend|reactivate (users:admin)",
+				"unlock":          "POST /api/v1/admin/users/:id/unlock (users:admin)",
+				"audit_logs":      "GET /api/v1/admin/audit-logs (users:admin)",
+				"api_key_quota":   "PUT /api/v1/admin/api-keys/:id/quota (users:admin)",
 			},
 			"health": "GET /health",
 		},
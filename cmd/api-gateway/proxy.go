@@ -0,0 +1,239 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+var (
+	errUpstreamTimeout = errors.New("upstream did not respond in time")
+	errRetryableStatus = errors.New("upstream answered with a retryable status")
+)
+
+type proxyAttemptKey struct{}
+
+// proxyAttempt carries one try at an upstream instance through the shared
+// ReverseProxy and brings back how it went.
+type proxyAttempt struct {
+	url      *url.URL
+	host     string
+	clientIP string
+	timer    *time.Timer
+	upstream *upstream
+	// canRetry is set when another instance may be tried after a failure
+	canRetry bool
+
+	failed bool
+	retry  bool
+}
+
+func newTransport() *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConns = 200
+	transport.MaxIdleConnsPerHost = 32
+	transport.IdleConnTimeout = 90 * time.Second
+	// Pass Accept-Encoding on as the client sent it instead of asking for
+	// gzip on its behalf and unpacking the response here
+	transport.DisableCompression = true
+	return transport
+}
+
+func (g *APIGateway) newReverseProxy() *httputil.ReverseProxy {
+	return &httputil.ReverseProxy{
+		Rewrite:        g.rewriteProxyRequest,
+		Transport:      newTransport(),
+		ModifyResponse: g.modifyProxyResponse,
+		ErrorHandler:   g.proxyError,
+		ErrorLog:       zap.NewStdLog(g.logger),
+		// SSE and responses without a length are flushed as they arrive;
+		// everything else is flushed at least this often
+		FlushInterval: 100 * time.Millisecond,
+	}
+}
+
+// proxyRequest streams the request to an instance of the route's upstream
+// and the response back. Requests that carry no body and use an idempotent
+// method move on to another instance when one fails before answering.
+func (g *APIGateway) proxyRequest(c *gin.Context, r *route, path string) {
+	maxBody := g.config.GatewayMaxBodyBytes
+	if maxBody > 0 {
+		if c.Request.ContentLength > maxBody {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Request body too large"})
+			return
+		}
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxBody)
+	}
+
+	u := r.upstream
+	u.budget.request()
+	retryable := idempotentMethods[c.Request.Method] && c.Request.ContentLength == 0
+
+	tried := make(map[*target]bool)
+	for attempt := 0; ; attempt++ {
+		t := u.pick(tried)
+		if t == nil {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Service unavailable"})
+			return
+		}
+		tried[t] = true
+
+		done, err := u.breaker.Allow()
+		if err != nil {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Service unavailable"})
+			return
+		}
+
+		targetURL := *t.url
+		targetURL.Path = path
+		targetURL.RawQuery = c.Request.URL.RawQuery
+
+		result := g.proxyAttempt(c, t, &proxyAttempt{
+			url:      &targetURL,
+			host:     t.host,
+			clientIP: c.ClientIP(),
+			upstream: u,
+			canRetry: retryable && attempt < u.config.Retry.Attempts,
+		}, r.config.Timeout)
+		done(!result.failed)
+
+		if !result.retry {
+			return
+		}
+		g.logger.Warn("Retrying on another instance", zap.String("upstream", u.name), zap.String("target", t.url.Host))
+	}
+}
+
+var idempotentMethods = map[string]bool{
+	http.MethodGet:     true,
+	http.MethodHead:    true,
+	http.MethodOptions: true,
+	http.MethodPut:     true,
+	http.MethodDelete:  true,
+}
+
+// proxyAttempt sends the request to one instance. timeout bounds the wait
+// for the response headers only, so long-running streams are not cut off.
+func (g *APIGateway) proxyAttempt(c *gin.Context, t *target, attempt *proxyAttempt, timeout time.Duration) *proxyAttempt {
+	ctx, cancel := context.WithCancelCause(c.Request.Context())
+	defer cancel(nil)
+
+	attempt.timer = time.AfterFunc(timeout, func() { cancel(errUpstreamTimeout) })
+	defer attempt.timer.Stop()
+
+	t.active.Add(1)
+	defer t.active.Add(-1)
+
+	ctx = context.WithValue(ctx, proxyAttemptKey{}, attempt)
+	g.proxy.ServeHTTP(c.Writer, c.Request.WithContext(ctx))
+	return attempt
+}
+
+// rewriteProxyRequest points the outgoing request at the chosen instance.
+// ReverseProxy has already dropped hop-by-hop and inbound X-Forwarded-*
+// headers; the gateway trusts no proxy in front of it, so they are
+// rebuilt from the connection alone.
+func (g *APIGateway) rewriteProxyRequest(pr *httputil.ProxyRequest) {
+	target := pr.In.Context().Value(proxyAttemptKey{}).(*proxyAttempt)
+
+	pr.Out.URL.Scheme = target.url.Scheme
+	pr.Out.URL.Host = target.url.Host
+	pr.Out.URL.Path = target.url.Path
+	pr.Out.URL.RawPath = target.url.RawPath
+	pr.Out.URL.RawQuery = target.url.RawQuery
+	// Upstreams resolved to an IP still expect their own name
+	pr.Out.Host = target.host
+
+	proto := "http"
+	if pr.In.TLS != nil {
+		proto = "https"
+	}
+
+	pr.Out.Header.Del("Forwarded")
+	pr.Out.Header.Set("X-Forwarded-For", target.clientIP)
+	pr.Out.Header.Set("X-Forwarded-Host", pr.In.Host)
+	pr.Out.Header.Set("X-Forwarded-Proto", proto)
+	pr.Out.Header.Set("Forwarded", "for="+forwardedNode(target.clientIP)+";host=\""+pr.In.Host+"\";proto="+proto)
+}
+
+func (g *APIGateway) modifyProxyResponse(resp *http.Response) error {
+	attempt := resp.Request.Context().Value(proxyAttemptKey{}).(*proxyAttempt)
+
+	// Headers are in; from here on the body may stream for as long as it needs
+	attempt.timer.Stop()
+
+	switch resp.StatusCode {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		attempt.failed = true
+		if attempt.canRetry && attempt.upstream.budget.allowRetry() {
+			attempt.retry = true
+			return errRetryableStatus
+		}
+	}
+
+	// The gateway answers CORS and reports rate limits itself; upstream
+	// copies would be duplicates
+	for key := range resp.Header {
+		if strings.HasPrefix(key, "Access-Control-") || strings.HasPrefix(key, "Ratelimit-") {
+			resp.Header.Del(key)
+		}
+	}
+	return nil
+}
+
+// proxyError answers for an attempt that got no usable response, unless
+// the attempt is to be retried on another instance.
+func (g *APIGateway) proxyError(w http.ResponseWriter, r *http.Request, err error) {
+	attempt := r.Context().Value(proxyAttemptKey{}).(*proxyAttempt)
+	if attempt.retry {
+		return
+	}
+
+	var maxBytesErr *http.MaxBytesError
+	switch {
+	case errors.As(err, &maxBytesErr):
+		writeProxyError(w, http.StatusRequestEntityTooLarge, "Request body too large")
+		return
+	case errors.Is(err, context.Canceled) && context.Cause(r.Context()) == context.Canceled:
+		// The client went away; that says nothing about the upstream and
+		// there is no one left to answer
+		return
+	}
+
+	attempt.failed = true
+	if attempt.canRetry && attempt.upstream.budget.allowRetry() {
+		attempt.retry = true
+		return
+	}
+
+	if errors.Is(context.Cause(r.Context()), errUpstreamTimeout) {
+		g.logger.Error("Proxy request timed out", zap.String("url", attempt.url.String()))
+		writeProxyError(w, http.StatusGatewayTimeout, "Service timed out")
+		return
+	}
+	g.logger.Error("Proxy request failed", zap.String("url", attempt.url.String()), zap.Error(err))
+	writeProxyError(w, http.StatusServiceUnavailable, "Service unavailable")
+}
+
+func writeProxyError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	w.Write([]byte(`{"error":"` + message + `"}`))
+}
+
+// forwardedNode formats an IP for the Forwarded header, which wants IPv6
+// addresses quoted and bracketed (RFC 7239).
+func forwardedNode(ip string) string {
+	if parsed := net.ParseIP(ip); parsed != nil && parsed.To4() == nil {
+		return `"[` + ip + `]"`
+	}
+	return ip
+}
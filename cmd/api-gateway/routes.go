@@ -0,0 +1,341 @@
+package main
+
+import (
+	"context"
+	_ "embed"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v3"
+
+	"news-aggregator/pkg/metrics"
+	"news-aggregator/pkg/middleware"
+	"news-aggregator/pkg/ratelimit"
+	"news-aggregator/pkg/rbac"
+)
+
+//go:embed routes.yaml
+var defaultRoutes []byte
+
+const defaultRouteTimeout = 30 * time.Second
+
+const (
+	routeAuthNone    = "none"
+	routeAuthJWT     = "jwt"
+	routeAuthSession = "session"
+)
+
+type routesConfig struct {
+	Upstreams map[string]upstreamConfig `yaml:"upstreams"`
+	Routes    []routeConfig             `yaml:"routes"`
+}
+
+type routeConfig struct {
+	Path        string          `yaml:"path"`
+	Prefix      string          `yaml:"prefix"`
+	Methods     []string        `yaml:"methods"`
+	Upstream    string          `yaml:"upstream"`
+	StripPrefix bool            `yaml:"strip_prefix"`
+	Rewrite     *string         `yaml:"rewrite"`
+	Auth        string          `yaml:"auth"`
+	Permissions []string        `yaml:"permissions"`
+	Scope       string          `yaml:"scope"`
+	Timeout     time.Duration   `yaml:"timeout"`
+	RateLimit   *routeRateLimit `yaml:"rate_limit"`
+}
+
+// routeRateLimit is a limit on one route, counted on top of the global one.
+type routeRateLimit struct {
+	Requests int            `yaml:"requests"`
+	Window   time.Duration  `yaml:"window"`
+	Roles    map[string]int `yaml:"roles"`
+}
+
+type route struct {
+	config   routeConfig
+	methods  map[string]bool
+	upstream *upstream
+	// handlers run before the request is proxied, e.g. JWTAuth
+	handlers []gin.HandlerFunc
+}
+
+// routeTable is immutable once built; a reload swaps in a new one.
+type routeTable struct {
+	routes    []*route
+	upstreams map[string]*upstream
+	// stop ends the health checks of the table's upstreams
+	stop context.CancelFunc
+}
+
+// loadRoutes reads the route table from path, or the built-in table when
+// path is empty.
+func (g *APIGateway) loadRoutes(path string) (*routeTable, error) {
+	data := defaultRoutes
+	if path != "" {
+		var err error
+		if data, err = os.ReadFile(path); err != nil {
+			return nil, err
+		}
+	}
+
+	var cfg routesConfig
+	if err := yaml.Unmarshal([]byte(expandEnv(string(data))), &cfg); err != nil {
+		return nil, fmt.Errorf("parse routes: %w", err)
+	}
+
+	table := &routeTable{upstreams: make(map[string]*upstream)}
+	for name, upstreamCfg := range cfg.Upstreams {
+		u, err := newUpstream(name, upstreamCfg)
+		if err != nil {
+			return nil, err
+		}
+		table.upstreams[name] = u
+	}
+
+	for i, routeCfg := range cfg.Routes {
+		r, err := g.buildRoute(routeCfg, table.upstreams)
+		if err != nil {
+			return nil, fmt.Errorf("route %d: %w", i+1, err)
+		}
+		table.routes = append(table.routes, r)
+	}
+
+	// Most specific first; ties keep file order
+	sort.SliceStable(table.routes, func(i, j int) bool {
+		a, b := table.routes[i].config, table.routes[j].config
+		if (a.Path != "") != (b.Path != "") {
+			return a.Path != ""
+		}
+		return len(a.Path+a.Prefix) > len(b.Path+b.Prefix)
+	})
+
+	return table, nil
+}
+
+func (g *APIGateway) buildRoute(cfg routeConfig, upstreams map[string]*upstream) (*route, error) {
+	if (cfg.Path == "") == (cfg.Prefix == "") {
+		return nil, fmt.Errorf("exactly one of path and prefix is required")
+	}
+	if cfg.StripPrefix && cfg.Rewrite != nil {
+		return nil, fmt.Errorf("strip_prefix and rewrite are mutually exclusive")
+	}
+	cfg.Prefix = strings.TrimRight(cfg.Prefix, "/")
+
+	u, ok := upstreams[cfg.Upstream]
+	if !ok {
+		return nil, fmt.Errorf("unknown upstream %q", cfg.Upstream)
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = defaultRouteTimeout
+	}
+
+	r := &route{config: cfg, upstream: u, methods: make(map[string]bool)}
+	for _, method := range cfg.Methods {
+		r.methods[strings.ToUpper(method)] = true
+	}
+
+	// Limits come first so floods are turned away before any token checks
+	if cfg.RateLimit != nil {
+		handler, err := g.routeRateLimit(cfg)
+		if err != nil {
+			return nil, err
+		}
+		r.handlers = append(r.handlers, handler)
+	}
+
+	switch cfg.Auth {
+	case "", routeAuthNone:
+		if len(cfg.Permissions) > 0 || cfg.Scope != "" {
+			return nil, fmt.Errorf("permissions and scope need auth")
+		}
+	case routeAuthJWT:
+		r.handlers = append(r.handlers, g.auth.JWTAuth())
+	case routeAuthSession:
+		r.handlers = append(r.handlers, g.auth.JWTAuth(), middleware.SessionOnly())
+	default:
+		return nil, fmt.Errorf("unknown auth %q", cfg.Auth)
+	}
+
+	for _, permission := range cfg.Permissions {
+		if !rbac.Has(rbac.RoleAdmin, permission) {
+			return nil, fmt.Errorf("unknown permission %q", permission)
+		}
+	}
+	if len(cfg.Permissions) > 0 {
+		r.handlers = append(r.handlers, middleware.RequirePermission(cfg.Permissions...))
+	}
+	if cfg.Scope != "" {
+		r.handlers = append(r.handlers, middleware.RequireScope(cfg.Scope))
+	}
+
+	return r, nil
+}
+
+func (g *APIGateway) routeRateLimit(cfg routeConfig) (gin.HandlerFunc, error) {
+	window := cfg.RateLimit.Window
+	if window <= 0 {
+		window = time.Duration(g.config.RateLimitWindow) * time.Second
+	}
+	if cfg.RateLimit.Requests <= 0 {
+		return nil, fmt.Errorf("rate_limit needs requests")
+	}
+
+	tiers := ratelimit.Tiers{
+		Default: ratelimit.Limit{Requests: cfg.RateLimit.Requests, Window: window},
+		Roles:   make(map[string]ratelimit.Limit),
+	}
+	for role, requests := range cfg.RateLimit.Roles {
+		if !rbac.ValidRole(role) || requests <= 0 {
+			return nil, fmt.Errorf("invalid rate_limit tier %s=%d", role, requests)
+		}
+		tiers.Roles[role] = ratelimit.Limit{Requests: requests, Window: window}
+	}
+
+	methods := make([]string, 0, len(cfg.Methods))
+	for _, method := range cfg.Methods {
+		methods = append(methods, strings.ToUpper(method))
+	}
+	sort.Strings(methods)
+
+	return g.auth.RateLimit(middleware.RateLimitOptions{
+		Scope:    "route:" + strings.Join(methods, ",") + ":" + cfg.Path + cfg.Prefix,
+		Tiers:    tiers,
+		FailOpen: g.config.RateLimitFailOpen,
+	}), nil
+}
+
+// match finds the route for a request. pathMatched reports whether some
+// route covers the path with another method, which makes a miss a 405.
+func (t *routeTable) match(method, path string) (r *route, pathMatched bool) {
+	for _, candidate := range t.routes {
+		if !candidate.matchesPath(path) {
+			continue
+		}
+		pathMatched = true
+		if len(candidate.methods) == 0 || candidate.methods[method] {
+			return candidate, true
+		}
+	}
+	return nil, pathMatched
+}
+
+func (r *route) matchesPath(path string) bool {
+	if r.config.Path != "" {
+		return path == r.config.Path
+	}
+	// Prefixes match whole segments: /api/v1/news covers /api/v1/news/1
+	// but not /api/v1/newsletter
+	return path == r.config.Prefix || strings.HasPrefix(path, r.config.Prefix+"/")
+}
+
+// upstreamPath applies the route's rewrite rules to path.
+func (r *route) upstreamPath(path string) string {
+	if r.config.Prefix == "" {
+		return path
+	}
+
+	rest := strings.TrimPrefix(path, r.config.Prefix)
+	switch {
+	case r.config.StripPrefix:
+		path = rest
+	case r.config.Rewrite != nil:
+		path = strings.TrimRight(*r.config.Rewrite, "/") + rest
+	}
+
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	return path
+}
+
+// dispatch routes every request that has no fixed handler through the
+// current route table.
+func (g *APIGateway) dispatch(c *gin.Context) {
+	r, pathMatched := g.routes.Load().match(c.Request.Method, c.Request.URL.Path)
+	if r == nil {
+		if pathMatched {
+			c.JSON(http.StatusMethodNotAllowed, gin.H{"error": "Method not allowed"})
+			return
+		}
+		c.JSON(http.StatusNotFound, gin.H{"error": "Not found"})
+		return
+	}
+
+	// Every proxied request is a NoRoute to gin; label it by its route
+	metrics.SetRoute(c, r.config.Path+r.config.Prefix)
+
+	// The handlers call c.Next(), which is a no-op here as dispatch is the
+	// last handler in gin's chain, so an abort is the only signal to stop
+	for _, handler := range r.handlers {
+		handler(c)
+		if c.IsAborted() {
+			return
+		}
+	}
+
+	g.proxyRequest(c, r, r.upstreamPath(c.Request.URL.Path))
+}
+
+// useRoutes makes table the live route table, starting health checks for
+// its upstreams and stopping those of the table it replaces.
+func (g *APIGateway) useRoutes(table *routeTable) {
+	ctx, cancel := context.WithCancel(context.Background())
+	table.stop = cancel
+
+	resolveEvery := time.Duration(g.config.GatewayDNSRefreshSeconds) * time.Second
+	for _, u := range table.upstreams {
+		go u.run(ctx, g.logger, resolveEvery)
+	}
+
+	if old := g.routes.Swap(table); old != nil {
+		old.stop()
+	}
+}
+
+// watchRoutes reloads the route table when its file changes. A broken file
+// is logged and the old table kept.
+func (g *APIGateway) watchRoutes(path string, every time.Duration) {
+	var modTime time.Time
+	if info, err := os.Stat(path); err == nil {
+		modTime = info.ModTime()
+	}
+
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		info, err := os.Stat(path)
+		if err != nil || info.ModTime().Equal(modTime) {
+			continue
+		}
+		modTime = info.ModTime()
+
+		table, err := g.loadRoutes(path)
+		if err != nil {
+			g.logger.Error("Failed to reload routes, keeping previous table", zap.String("file", path), zap.Error(err))
+			continue
+		}
+		g.useRoutes(table)
+		g.logger.Info("Routes reloaded", zap.String("file", path), zap.Int("routes", len(table.routes)))
+	}
+}
+
+// expandEnv replaces ${VAR} and ${VAR:-default} with values from the
+// environment.
+func expandEnv(s string) string {
+	return os.Expand(s, func(name string) string {
+		if i := strings.Index(name, ":-"); i >= 0 {
+			if value := os.Getenv(name[:i]); value != "" {
+				return value
+			}
+			return name[i+2:]
+		}
+		return os.Getenv(name)
+	})
+}
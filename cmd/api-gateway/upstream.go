@@ -0,0 +1,407 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sony/gobreaker"
+	"go.uber.org/zap"
+)
+
+const (
+	discoveryStatic = "static"
+	discoveryDNS    = "dns"
+
+	balanceRoundRobin       = "round_robin"
+	balanceLeastConnections = "least_connections"
+)
+
+type upstreamConfig struct {
+	Discovery      string               `yaml:"discovery"`
+	Targets        []string             `yaml:"targets"`
+	Balance        string               `yaml:"balance"`
+	HealthCheck    healthCheckConfig    `yaml:"health_check"`
+	Retry          retryConfig          `yaml:"retry"`
+	CircuitBreaker circuitBreakerConfig `yaml:"circuit_breaker"`
+}
+
+type healthCheckConfig struct {
+	Path               string        `yaml:"path"`
+	Interval           time.Duration `yaml:"interval"`
+	Timeout            time.Duration `yaml:"timeout"`
+	UnhealthyThreshold int           `yaml:"unhealthy_threshold"`
+	HealthyThreshold   int           `yaml:"healthy_threshold"`
+}
+
+type retryConfig struct {
+	// Attempts is how many more instances a failed request may try
+	Attempts int `yaml:"attempts"`
+	// Budget caps retries at this share of the upstream's requests, with
+	// MinPerSecond allowed regardless so quiet upstreams can still retry
+	Budget       float64 `yaml:"budget"`
+	MinPerSecond int     `yaml:"min_per_second"`
+}
+
+type circuitBreakerConfig struct {
+	Failures uint32        `yaml:"failures"`
+	OpenFor  time.Duration `yaml:"open_for"`
+}
+
+// target is one instance of an upstream. host is the Host header to send,
+// which differs from url.Host once a name has been resolved to an IP.
+type target struct {
+	url  *url.URL
+	host string
+
+	healthy   atomic.Bool
+	active    atomic.Int64
+	passes    int
+	failures  int
+	lastError string
+}
+
+type upstream struct {
+	name    string
+	config  upstreamConfig
+	seeds   []*url.URL
+	targets atomic.Pointer[[]*target]
+	next    atomic.Uint64
+	breaker *gobreaker.TwoStepCircuitBreaker
+	budget  *retryBudget
+	// mu guards the health counters of the targets
+	mu sync.Mutex
+}
+
+func newUpstream(name string, cfg upstreamConfig) (*upstream, error) {
+	if cfg.Discovery == "" {
+		cfg.Discovery = discoveryStatic
+	}
+	if cfg.Discovery != discoveryStatic && cfg.Discovery != discoveryDNS {
+		return nil, fmt.Errorf("upstream %s: unknown discovery %q", name, cfg.Discovery)
+	}
+	if cfg.Balance == "" {
+		cfg.Balance = balanceRoundRobin
+	}
+	if cfg.Balance != balanceRoundRobin && cfg.Balance != balanceLeastConnections {
+		return nil, fmt.Errorf("upstream %s: unknown balance %q", name, cfg.Balance)
+	}
+	if len(cfg.Targets) == 0 {
+		return nil, fmt.Errorf("upstream %s: no targets", name)
+	}
+	applyUpstreamDefaults(&cfg)
+
+	u := &upstream{
+		name:   name,
+		config: cfg,
+		budget: &retryBudget{ratio: cfg.Retry.Budget, minPerSecond: cfg.Retry.MinPerSecond},
+	}
+	for _, raw := range cfg.Targets {
+		seed, err := url.Parse(raw)
+		if err != nil || seed.Scheme == "" || seed.Host == "" {
+			return nil, fmt.Errorf("upstream %s: invalid target %q", name, raw)
+		}
+		u.seeds = append(u.seeds, seed)
+	}
+
+	u.breaker = gobreaker.NewTwoStepCircuitBreaker(gobreaker.Settings{
+		Name:        name,
+		MaxRequests: 1,
+		Timeout:     cfg.CircuitBreaker.OpenFor,
+		ReadyToTrip: func(counts gobreaker.Counts) bool {
+			return counts.ConsecutiveFailures >= cfg.CircuitBreaker.Failures
+		},
+	})
+
+	targets := make([]*target, 0, len(u.seeds))
+	for _, seed := range u.seeds {
+		targets = append(targets, newTarget(seed, seed.Host))
+	}
+	u.targets.Store(&targets)
+	u.resolve()
+
+	return u, nil
+}
+
+func applyUpstreamDefaults(cfg *upstreamConfig) {
+	if cfg.HealthCheck.Path == "" {
+		cfg.HealthCheck.Path = "/health"
+	}
+	if cfg.HealthCheck.Interval <= 0 {
+		cfg.HealthCheck.Interval = 10 * time.Second
+	}
+	if cfg.HealthCheck.Timeout <= 0 {
+		cfg.HealthCheck.Timeout = 2 * time.Second
+	}
+	if cfg.HealthCheck.UnhealthyThreshold <= 0 {
+		cfg.HealthCheck.UnhealthyThreshold = 2
+	}
+	if cfg.HealthCheck.HealthyThreshold <= 0 {
+		cfg.HealthCheck.HealthyThreshold = 2
+	}
+	if cfg.Retry.Attempts < 0 {
+		cfg.Retry.Attempts = 0
+	}
+	if cfg.Retry.Budget <= 0 {
+		cfg.Retry.Budget = 0.2
+	}
+	if cfg.Retry.MinPerSecond <= 0 {
+		cfg.Retry.MinPerSecond = 3
+	}
+	if cfg.CircuitBreaker.Failures == 0 {
+		cfg.CircuitBreaker.Failures = 5
+	}
+	if cfg.CircuitBreaker.OpenFor <= 0 {
+		cfg.CircuitBreaker.OpenFor = 30 * time.Second
+	}
+}
+
+// newTarget starts out healthy so traffic flows before the first check.
+func newTarget(u *url.URL, host string) *target {
+	t := &target{url: u, host: host}
+	t.healthy.Store(true)
+	return t
+}
+
+// run health-checks the targets and, for dns upstreams, re-resolves them
+// until ctx is cancelled.
+func (u *upstream) run(ctx context.Context, logger *zap.Logger, resolveEvery time.Duration) {
+	check := time.NewTicker(u.config.HealthCheck.Interval)
+	defer check.Stop()
+
+	// A nil channel never fires, which leaves static upstreams alone
+	var resolveC <-chan time.Time
+	if u.config.Discovery == discoveryDNS && resolveEvery > 0 {
+		resolve := time.NewTicker(resolveEvery)
+		defer resolve.Stop()
+		resolveC = resolve.C
+	}
+
+	client := &http.Client{Timeout: u.config.HealthCheck.Timeout}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-check.C:
+			u.checkTargets(ctx, client, logger)
+		case <-resolveC:
+			u.resolve()
+		}
+	}
+}
+
+func (u *upstream) checkTargets(ctx context.Context, client *http.Client, logger *zap.Logger) {
+	var wg sync.WaitGroup
+	for _, t := range u.all() {
+		wg.Add(1)
+		go func(t *target) {
+			defer wg.Done()
+			u.record(t, u.probe(ctx, client, t), logger)
+		}(t)
+	}
+	wg.Wait()
+}
+
+func (u *upstream) probe(ctx context.Context, client *http.Client, t *target) error {
+	healthURL := *t.url
+	healthURL.Path = u.config.HealthCheck.Path
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, healthURL.String(), nil)
+	if err != nil {
+		return err
+	}
+	req.Host = t.host
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("health check returned %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// record applies a probe result. A target is ejected after
+// unhealthy_threshold failures in a row and comes back after
+// healthy_threshold passes in a row.
+func (u *upstream) record(t *target, err error, logger *zap.Logger) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if err != nil {
+		t.passes = 0
+		t.failures++
+		t.lastError = err.Error()
+		if t.healthy.Load() && t.failures >= u.config.HealthCheck.UnhealthyThreshold {
+			t.healthy.Store(false)
+			logger.Warn("Upstream instance ejected", zap.String("upstream", u.name), zap.String("target", t.url.Host), zap.Error(err))
+		}
+		return
+	}
+
+	t.failures = 0
+	t.passes++
+	t.lastError = ""
+	if !t.healthy.Load() && t.passes >= u.config.HealthCheck.HealthyThreshold {
+		t.healthy.Store(true)
+		logger.Info("Upstream instance restored", zap.String("upstream", u.name), zap.String("target", t.url.Host))
+	}
+}
+
+// resolve refreshes the addresses of a dns upstream. A lookup that fails
+// or comes back empty leaves the previous addresses in place; addresses
+// that stay keep their health state.
+func (u *upstream) resolve() {
+	if u.config.Discovery != discoveryDNS {
+		return
+	}
+
+	known := make(map[string]*target)
+	for _, t := range u.all() {
+		known[t.url.Host] = t
+	}
+
+	var targets []*target
+	for _, seed := range u.seeds {
+		addrs, err := net.LookupHost(seed.Hostname())
+		if err != nil || len(addrs) == 0 {
+			return
+		}
+		for _, addr := range addrs {
+			resolved := *seed
+			resolved.Host = addr
+			if port := seed.Port(); port != "" {
+				resolved.Host = net.JoinHostPort(addr, port)
+			} else if net.ParseIP(addr).To4() == nil {
+				resolved.Host = "[" + addr + "]"
+			}
+
+			if t, ok := known[resolved.Host]; ok {
+				targets = append(targets, t)
+				continue
+			}
+			targets = append(targets, newTarget(&resolved, seed.Host))
+		}
+	}
+	u.targets.Store(&targets)
+}
+
+// pick returns a healthy target not in exclude, by round robin or fewest
+// requests in flight. It returns nil when none is left.
+func (u *upstream) pick(exclude map[*target]bool) *target {
+	var candidates []*target
+	for _, t := range u.all() {
+		if t.healthy.Load() && !exclude[t] {
+			candidates = append(candidates, t)
+		}
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+
+	// Rotating the starting point also spreads ties in least_connections
+	start := int((u.next.Add(1) - 1) % uint64(len(candidates)))
+	if u.config.Balance == balanceRoundRobin {
+		return candidates[start]
+	}
+
+	best := candidates[start]
+	for i := 1; i < len(candidates); i++ {
+		t := candidates[(start+i)%len(candidates)]
+		if t.active.Load() < best.active.Load() {
+			best = t
+		}
+	}
+	return best
+}
+
+func (u *upstream) all() []*target {
+	return *u.targets.Load()
+}
+
+func (u *upstream) status() gin.H {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	healthy := 0
+	targets := make([]gin.H, 0, len(u.all()))
+	for _, t := range u.all() {
+		entry := gin.H{
+			"address": t.url.Host,
+			"healthy": t.healthy.Load(),
+			"active":  t.active.Load(),
+		}
+		if t.lastError != "" {
+			entry["error"] = t.lastError
+		}
+		if t.healthy.Load() {
+			healthy++
+		}
+		targets = append(targets, entry)
+	}
+
+	status := "healthy"
+	if healthy == 0 || u.breaker.State() == gobreaker.StateOpen {
+		status = "unhealthy"
+	}
+
+	return gin.H{
+		"status":          status,
+		"circuit_breaker": u.breaker.State().String(),
+		"targets":         targets,
+	}
+}
+
+// retryBudget lets retries add at most ratio on top of the requests seen in
+// the last few seconds, so a struggling upstream is not hit with a wave of
+// retries on top of its normal load.
+type retryBudget struct {
+	ratio        float64
+	minPerSecond int
+
+	mu          sync.Mutex
+	windowStart time.Time
+	requests    int
+	retries     int
+}
+
+const retryBudgetWindow = 10 * time.Second
+
+func (b *retryBudget) request() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.roll()
+	b.requests++
+}
+
+// allowRetry reports whether a retry fits in the budget and, if so,
+// counts it.
+func (b *retryBudget) allowRetry() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.roll()
+
+	allowed := int(b.ratio*float64(b.requests)) + b.minPerSecond*int(retryBudgetWindow/time.Second)
+	if b.retries >= allowed {
+		return false
+	}
+	b.retries++
+	return true
+}
+
+func (b *retryBudget) roll() {
+	if now := time.Now(); now.Sub(b.windowStart) >= retryBudgetWindow {
+		b.windowStart = now
+		b.requests = 0
+		b.retries = 0
+	}
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"news-aggregator/pkg/mailer"
+	"news-aggregator/pkg/middleware"
+	"news-aggregator/pkg/models"
+)
+
+var errUserTokenInvalid = errors.New("invalid or expired token")
+
+// forgotPassword mails a reset link. It answers the same way whether or not
+// the address belongs to an account, so it cannot be used to probe for users.
+func (s *AuthService) forgotPassword(c *gin.Context) {
+	var req models.ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var user models.User
+	err := s.db.Where("LOWER(email) = LOWER(?) AND status = ?", req.Email, models.UserStatusActive).First(&user).Error
+	if err == nil {
+		ttl := time.Duration(s.config.PasswordResetTTLMinutes) * time.Minute
+		token, err := s.createUserToken(user.ID, models.TokenPurposePasswordReset, ttl)
+		if err != nil {
+			log.Printf("Failed to create reset token for user %d: %v", user.ID, err)
+		} else {
+			go s.sendMail(mailer.Message{
+				To:      user.Email,
+				Subject: "Reset your password",
+				Body: fmt.Sprintf("Hi %s,\n\nSomeone asked to reset the password for your account. "+
+					"Use the link below within %d minutes to choose a new one:\n\n%s\n\n"+
+					"If this wasn't you, you can ignore this email.\n",
+					user.Username, s.config.PasswordResetTTLMinutes, s.appLink("/reset-password", token)),
+			})
+		}
+	} else if err != gorm.ErrRecordNotFound {
+		log.Printf("Failed to look up user for password reset: %v", err)
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "If the address belongs to an account, a reset link has been sent"})
+}
+
+func (s *AuthService) resetPassword(c *gin.Context) {
+	var req models.ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
+		return
+	}
+
+	var userID uint
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		token, err := consumeUserToken(tx, req.Token, models.TokenPurposePasswordReset)
+		if err != nil {
+			return err
+		}
+		userID = token.UserID
+
+		// The link arrived by mail, which proves the address as well
+		return tx.Model(&models.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+			"password":          string(hashedPassword),
+			"email_verified_at": gorm.Expr("COALESCE(email_verified_at, ?)", time.Now()),
+		}).Error
+	})
+	if err == errUserTokenInvalid {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired token"})
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to reset password: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset password"})
+		return
+	}
+
+	// Whoever had the old password should not stay logged in
+	s.revokeUserTokens(c.Request.Context(), userID, true)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password has been reset"})
+}
+
+func (s *AuthService) verifyEmail(c *gin.Context) {
+	var req models.VerifyEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		token, err := consumeUserToken(tx, req.Token, models.TokenPurposeVerifyEmail)
+		if err != nil {
+			return err
+		}
+
+		return tx.Model(&models.User{}).
+			Where("id = ? AND email_verified_at IS NULL", token.UserID).
+			Update("email_verified_at", time.Now()).Error
+	})
+	if err == errUserTokenInvalid {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired token"})
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to verify email: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify email"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Email verified"})
+}
+
+func (s *AuthService) resendVerification(c *gin.Context) {
+	userID, _ := middleware.UserID(c)
+
+	var user models.User
+	if err := s.db.Where("id = ?", userID).First(&user).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if user.EmailVerifiedAt != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Email already verified"})
+		return
+	}
+
+	if err := s.sendVerification(user); err != nil {
+		log.Printf("Failed to send verification email to user %d: %v", user.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send verification email"})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "Verification email sent"})
+}
+
+func (s *AuthService) sendVerification(user models.User) error {
+	ttl := time.Duration(s.config.EmailVerificationTTLHours) * time.Hour
+	token, err := s.createUserToken(user.ID, models.TokenPurposeVerifyEmail, ttl)
+	if err != nil {
+		return err
+	}
+
+	go s.sendMail(mailer.Message{
+		To:      user.Email,
+		Subject: "Confirm your email address",
+		Body: fmt.Sprintf("Hi %s,\n\nPlease confirm your email address by opening the link below "+
+			"within %d hours:\n\n%s\n",
+			user.Username, s.config.EmailVerificationTTLHours, s.appLink("/verify-email", token)),
+	})
+	return nil
+}
+
+// createUserToken issues a token for purpose and retires any earlier unused
+// ones, so only the most recent email works.
+func (s *AuthService) createUserToken(userID uint, purpose string, ttl time.Duration) (string, error) {
+	token, err := newOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.UserToken{}).
+			Where("user_id = ? AND purpose = ? AND used_at IS NULL", userID, purpose).
+			Update("used_at", time.Now()).Error; err != nil {
+			return err
+		}
+
+		return tx.Create(&models.UserToken{
+			UserID:    userID,
+			Purpose:   purpose,
+			TokenHash: hashToken(token),
+			ExpiresAt: time.Now().Add(ttl),
+		}).Error
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// consumeUserToken marks a valid token as used within tx and returns it.
+func consumeUserToken(tx *gorm.DB, token, purpose string) (*models.UserToken, error) {
+	var record models.UserToken
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("token_hash = ? AND purpose = ?", hashToken(token), purpose).
+		First(&record).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, errUserTokenInvalid
+		}
+		return nil, err
+	}
+
+	if record.UsedAt != nil || time.Now().After(record.ExpiresAt) {
+		return nil, errUserTokenInvalid
+	}
+
+	if err := tx.Model(&record).Update("used_at", time.Now()).Error; err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+func (s *AuthService) appLink(path, token string) string {
+	return fmt.Sprintf("%s%s?token=%s", s.config.AppBaseURL, path, url.QueryEscape(token))
+}
+
+func (s *AuthService) sendMail(msg mailer.Message) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if err := s.mailer.Send(ctx, msg); err != nil {
+		log.Printf("Failed to send mail to %s: %v", msg.To, err)
+	}
+}
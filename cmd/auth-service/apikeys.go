@@ -0,0 +1,241 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"news-aggregator/pkg/apikey"
+	"news-aggregator/pkg/middleware"
+	"news-aggregator/pkg/models"
+	"news-aggregator/pkg/rbac"
+)
+
+// keyPrefixLength is how much of a key is kept in the clear for display.
+const keyPrefixLength = len(apikey.Prefix) + 6
+
+func (s *AuthService) createAPIKey(c *gin.Context) {
+	var req models.CreateAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, ok := s.currentUser(c)
+	if !ok {
+		return
+	}
+
+	for _, scope := range req.Scopes {
+		if !rbac.ValidScope(user.Role, scope) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Scope not allowed: " + scope, "scopes": rbac.Scopes(user.Role)})
+			return
+		}
+	}
+
+	// Users may lower their key's quota but only admins raise it
+	rateLimit := s.config.APIKeyRateLimit
+	if rateLimit <= 0 {
+		log.Printf("API_KEY_RATE_LIMIT is %d; it must be positive to create API keys", rateLimit)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create API key"})
+		return
+	}
+	if req.RateLimit > 0 {
+		if req.RateLimit > rateLimit {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Rate limit above the allowed maximum", "max_rate_limit": rateLimit})
+			return
+		}
+		rateLimit = req.RateLimit
+	}
+
+	var active int64
+	s.db.Model(&models.APIKey{}).Scopes(activeAPIKeys).Where("user_id = ?", user.ID).Count(&active)
+	if int(active) >= s.config.APIKeyMaxPerUser {
+		c.JSON(http.StatusConflict, gin.H{"error": "Too many API keys"})
+		return
+	}
+
+	key, hash, err := apikey.Generate()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create API key"})
+		return
+	}
+
+	record := models.APIKey{
+		UserID:    user.ID,
+		Name:      req.Name,
+		KeyPrefix: key[:keyPrefixLength],
+		KeyHash:   hash,
+		Scopes:    req.Scopes,
+		RateLimit: rateLimit,
+	}
+	if req.ExpiresInDays > 0 {
+		expiresAt := time.Now().AddDate(0, 0, req.ExpiresInDays)
+		record.ExpiresAt = &expiresAt
+	}
+
+	if err := s.db.Create(&record).Error; err != nil {
+		log.Printf("Failed to create API key for user %d: %v", user.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create API key"})
+		return
+	}
+
+	if err := s.apiKeys.Put(c.Request.Context(), hash, apiKeyIdentity(record, user)); err != nil {
+		log.Printf("Failed to publish API key %d: %v", record.ID, err)
+		s.db.Delete(&record)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create API key"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.CreateAPIKeyResponse{APIKey: record, Key: key})
+}
+
+func (s *AuthService) listAPIKeys(c *gin.Context) {
+	userID, _ := middleware.UserID(c)
+
+	var keys []models.APIKey
+	if err := s.db.Where("user_id = ?", userID).Order("id DESC").Find(&keys).Error; err != nil {
+		log.Printf("Failed to fetch API keys: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch API keys"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": keys})
+}
+
+func (s *AuthService) revokeAPIKey(c *gin.Context) {
+	userID, _ := middleware.UserID(c)
+
+	var key models.APIKey
+	if err := s.db.Where("id = ? AND user_id = ?", c.Param("id"), userID).First(&key).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "API key not found"})
+			return
+		}
+		log.Printf("Failed to fetch API key: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch API key"})
+		return
+	}
+
+	if key.RevokedAt == nil {
+		if err := s.db.Model(&key).Update("revoked_at", time.Now()).Error; err != nil {
+			log.Printf("Failed to revoke API key %d: %v", key.ID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke API key"})
+			return
+		}
+	}
+	if err := s.apiKeys.Delete(c.Request.Context(), key.KeyHash); err != nil {
+		log.Printf("Failed to unpublish API key %d: %v", key.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke API key"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "API key revoked"})
+}
+
+func (s *AuthService) updateAPIKeyQuota(c *gin.Context) {
+	var req models.UpdateAPIKeyQuotaRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var key models.APIKey
+	if err := s.db.Where("id = ?", c.Param("id")).First(&key).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "API key not found"})
+			return
+		}
+		log.Printf("Failed to fetch API key: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch API key"})
+		return
+	}
+
+	if err := s.db.Model(&key).Update("rate_limit", req.RateLimit).Error; err != nil {
+		log.Printf("Failed to update quota of API key %d: %v", key.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update API key"})
+		return
+	}
+	s.db.First(&key, key.ID)
+
+	s.syncAPIKeys(c.Request.Context(), key.UserID)
+
+	c.JSON(http.StatusOK, key)
+}
+
+// syncAPIKeys republishes the user's active keys so a new role, quota or
+// suspension applies to them at once.
+func (s *AuthService) syncAPIKeys(ctx context.Context, userID uint) {
+	var user models.User
+	if err := s.db.Where("id = ?", userID).First(&user).Error; err != nil {
+		log.Printf("Failed to sync API keys for user %d: %v", userID, err)
+		return
+	}
+
+	var keys []models.APIKey
+	if err := s.db.Scopes(activeAPIKeys).Where("user_id = ?", userID).Find(&keys).Error; err != nil {
+		log.Printf("Failed to sync API keys for user %d: %v", userID, err)
+		return
+	}
+
+	for _, key := range keys {
+		var err error
+		if user.Status == models.UserStatusSuspended {
+			err = s.apiKeys.Delete(ctx, key.KeyHash)
+		} else {
+			err = s.apiKeys.Put(ctx, key.KeyHash, apiKeyIdentity(key, user))
+		}
+		if err != nil {
+			log.Printf("Failed to sync API key %d: %v", key.ID, err)
+		}
+	}
+}
+
+// resyncAPIKeys republishes every active key at startup and then every
+// API_KEY_SYNC_MINUTES, so keys start working again soon after Redis is
+// flushed, fails over or evicts them. 0 syncs only at startup.
+func (s *AuthService) resyncAPIKeys() {
+	s.syncAllAPIKeys()
+	if s.config.APIKeySyncMinutes <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(time.Duration(s.config.APIKeySyncMinutes) * time.Minute)
+	defer ticker.Stop()
+	for range ticker.C {
+		s.syncAllAPIKeys()
+	}
+}
+
+// syncAllAPIKeys republishes every active key.
+func (s *AuthService) syncAllAPIKeys() {
+	var userIDs []uint
+	if err := s.db.Model(&models.APIKey{}).Scopes(activeAPIKeys).Distinct().Pluck("user_id", &userIDs).Error; err != nil {
+		log.Printf("Failed to sync API keys: %v", err)
+		return
+	}
+
+	for _, userID := range userIDs {
+		s.syncAPIKeys(context.Background(), userID)
+	}
+}
+
+func activeAPIKeys(db *gorm.DB) *gorm.DB {
+	return db.Where("revoked_at IS NULL AND (expires_at IS NULL OR expires_at > ?)", time.Now())
+}
+
+func apiKeyIdentity(key models.APIKey, user models.User) apikey.Identity {
+	return apikey.Identity{
+		ID:        key.ID,
+		UserID:    user.ID,
+		Username:  user.Username,
+		Role:      user.Role,
+		Scopes:    key.Scopes,
+		RateLimit: key.RateLimit,
+		ExpiresAt: key.ExpiresAt,
+	}
+}
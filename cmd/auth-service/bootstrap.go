@@ -0,0 +1,68 @@
+package main
+
+import (
+	"log"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+
+	"news-aggregator/pkg/config"
+	"news-aggregator/pkg/models"
+	"news-aggregator/pkg/rbac"
+)
+
+// bootstrapAdmin makes sure there is someone who can manage users. While no
+// admin exists, the account named by ADMIN_USERNAME is created from
+// ADMIN_EMAIL and ADMIN_PASSWORD. An existing account of that name is only
+// promoted when ADMIN_PASSWORD is its password, since anyone could have
+// registered the name before the first boot. Once an admin exists the
+// variables are ignored, so they can be left in place.
+func bootstrapAdmin(db *gorm.DB, cfg *config.Config) error {
+	if cfg.AdminUsername == "" {
+		return nil
+	}
+
+	var admins int64
+	if err := db.Model(&models.User{}).Where("role = ?", rbac.RoleAdmin).Count(&admins).Error; err != nil {
+		return err
+	}
+	if admins > 0 {
+		return nil
+	}
+
+	var user models.User
+	err := db.Where("username = ?", cfg.AdminUsername).First(&user).Error
+	switch {
+	case err == nil:
+		if cfg.AdminPassword == "" || bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(cfg.AdminPassword)) != nil {
+			log.Printf("Not promoting existing account %s to admin: ADMIN_PASSWORD does not match its password", user.Username)
+			return nil
+		}
+		log.Printf("Promoting %s to admin", user.Username)
+		return db.Model(&user).Updates(map[string]interface{}{
+			"role":   rbac.RoleAdmin,
+			"status": models.UserStatusActive,
+		}).Error
+	case err != gorm.ErrRecordNotFound:
+		return err
+	}
+
+	if cfg.AdminEmail == "" || cfg.AdminPassword == "" {
+		log.Printf("No admin account exists; set ADMIN_EMAIL and ADMIN_PASSWORD to create %s", cfg.AdminUsername)
+		return nil
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(cfg.AdminPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Creating admin account %s", cfg.AdminUsername)
+	return db.Create(&models.User{
+		Username: cfg.AdminUsername,
+		Email:    normalizeEmail(cfg.AdminEmail),
+		Password: string(hashedPassword),
+		Role:     rbac.RoleAdmin,
+		Status:   models.UserStatusActive,
+	}).Error
+}
@@ -0,0 +1,355 @@
+package main
+
+import (
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"news-aggregator/pkg/jwks"
+	"news-aggregator/pkg/models"
+)
+
+// keyLockID serialises rotation across auth-service replicas.
+const keyLockID = 7340012
+
+var errNoSigningKey = errors.New("no active signing key")
+
+type signingKey struct {
+	kid       string
+	method    jwt.SigningMethod
+	private   crypto.Signer
+	activeAt  time.Time
+	expiresAt *time.Time
+}
+
+// keyStore holds the signing keys from the database. Rotation publishes the
+// next key publishAhead before it starts signing, so verifiers with a cached
+// JWKS already know it by the time the first token carries its kid.
+type keyStore struct {
+	db           *gorm.DB
+	cipher       *keyCipher
+	alg          string
+	rotation     time.Duration
+	publishAhead time.Duration
+	retain       time.Duration
+
+	mu   sync.RWMutex
+	keys []signingKey // sorted by activeAt, oldest first
+}
+
+func newKeyStore(db *gorm.DB, encryptionKey, alg string, rotation, publishAhead, retain time.Duration) (*keyStore, error) {
+	if _, err := signingMethod(alg); err != nil {
+		return nil, err
+	}
+	kc, err := newKeyCipher(encryptionKey)
+	if err != nil {
+		return nil, err
+	}
+
+	ks := &keyStore{
+		db:           db,
+		cipher:       kc,
+		alg:          alg,
+		rotation:     rotation,
+		publishAhead: publishAhead,
+		retain:       retain,
+	}
+	if err := ks.rotate(); err != nil {
+		return nil, err
+	}
+	return ks, nil
+}
+
+// run reloads keys every minute, picking up keys made by other replicas, and
+// rotates when the current key is due.
+func (ks *keyStore) run() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if err := ks.rotate(); err != nil {
+			log.Printf("Failed to rotate signing keys: %v", err)
+		}
+	}
+}
+
+func (ks *keyStore) rotate() error {
+	err := ks.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", keyLockID).Error; err != nil {
+			return err
+		}
+		if err := ks.encryptLegacyKeys(tx); err != nil {
+			return err
+		}
+
+		var newest models.SigningKey
+		err := tx.Order("active_at DESC").First(&newest).Error
+		switch {
+		case err == gorm.ErrRecordNotFound:
+			// First start: nobody holds tokens yet, so sign right away
+			return ks.createKey(tx, time.Now())
+		case err != nil:
+			return err
+		case time.Now().After(newest.ActiveAt.Add(ks.rotation - ks.publishAhead)):
+			activeAt := time.Now().Add(ks.publishAhead)
+			if err := ks.createKey(tx, activeAt); err != nil {
+				return err
+			}
+
+			// Older keys stop signing at activeAt; keep them for verification
+			// until the last token they signed has expired.
+			return tx.Model(&models.SigningKey{}).
+				Where("expires_at IS NULL AND active_at < ?", activeAt).
+				Update("expires_at", activeAt.Add(ks.retain)).Error
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := ks.db.Where("expires_at < ?", time.Now().Add(-24*time.Hour)).
+		Delete(&models.SigningKey{}).Error; err != nil {
+		log.Printf("Failed to clean up signing keys: %v", err)
+	}
+
+	return ks.load()
+}
+
+func (ks *keyStore) createKey(tx *gorm.DB, activeAt time.Time) error {
+	var private crypto.Signer
+	var err error
+	switch ks.alg {
+	case "EdDSA":
+		_, private, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		private, err = rsa.GenerateKey(rand.Reader, 2048)
+	}
+	if err != nil {
+		return err
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		return err
+	}
+
+	kid := uuid.NewString()
+	sealed, err := ks.cipher.seal(kid, der)
+	if err != nil {
+		return err
+	}
+
+	return tx.Create(&models.SigningKey{
+		KID:        kid,
+		Algorithm:  ks.alg,
+		PrivateKey: sealed,
+		Encrypted:  true,
+		ActiveAt:   activeAt,
+	}).Error
+}
+
+// encryptLegacyKeys seals keys stored in plain text by releases that did not
+// encrypt them.
+func (ks *keyStore) encryptLegacyKeys(tx *gorm.DB) error {
+	var legacy []models.SigningKey
+	if err := tx.Where("encrypted = ?", false).Find(&legacy).Error; err != nil {
+		return err
+	}
+
+	for _, record := range legacy {
+		sealed, err := ks.cipher.seal(record.KID, record.PrivateKey)
+		if err != nil {
+			return err
+		}
+		if err := tx.Model(&record).Updates(map[string]interface{}{
+			"private_key": sealed,
+			"encrypted":   true,
+		}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (ks *keyStore) load() error {
+	var records []models.SigningKey
+	if err := ks.db.Where("expires_at IS NULL OR expires_at > ?", time.Now()).
+		Order("active_at").
+		Find(&records).Error; err != nil {
+		return err
+	}
+
+	keys := make([]signingKey, 0, len(records))
+	for _, record := range records {
+		method, err := signingMethod(record.Algorithm)
+		if err != nil {
+			log.Printf("Skipping signing key %s: %v", record.KID, err)
+			continue
+		}
+
+		der, err := ks.cipher.open(record.KID, record.PrivateKey)
+		if err != nil {
+			log.Printf("Skipping signing key %s: %v", record.KID, err)
+			continue
+		}
+		parsed, err := x509.ParsePKCS8PrivateKey(der)
+		if err != nil {
+			log.Printf("Skipping signing key %s: %v", record.KID, err)
+			continue
+		}
+		private, ok := parsed.(crypto.Signer)
+		if !ok {
+			log.Printf("Skipping signing key %s: unsupported key type %T", record.KID, parsed)
+			continue
+		}
+
+		keys = append(keys, signingKey{
+			kid:       record.KID,
+			method:    method,
+			private:   private,
+			activeAt:  record.ActiveAt,
+			expiresAt: record.ExpiresAt,
+		})
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].activeAt.Before(keys[j].activeAt) })
+
+	ks.mu.Lock()
+	ks.keys = keys
+	ks.mu.Unlock()
+
+	return nil
+}
+
+// current returns the newest key that is allowed to sign.
+func (ks *keyStore) current() (signingKey, error) {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	now := time.Now()
+	for i := len(ks.keys) - 1; i >= 0; i-- {
+		if !ks.keys[i].activeAt.After(now) {
+			return ks.keys[i], nil
+		}
+	}
+	return signingKey{}, errNoSigningKey
+}
+
+func (ks *keyStore) sign(claims jwt.Claims) (string, error) {
+	key, err := ks.current()
+	if err != nil {
+		return "", err
+	}
+
+	token := jwt.NewWithClaims(key.method, claims)
+	token.Header["kid"] = key.kid
+	return token.SignedString(key.private)
+}
+
+// keyfunc verifies tokens locally without going through the JWKS endpoint.
+func (ks *keyStore) keyfunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	for _, key := range ks.keys {
+		if key.kid != kid {
+			continue
+		}
+		if key.method.Alg() != token.Method.Alg() {
+			return nil, fmt.Errorf("key %s is for %s, token uses %s", kid, key.method.Alg(), token.Method.Alg())
+		}
+		return key.private.Public(), nil
+	}
+	return nil, fmt.Errorf("%w: %s", jwks.ErrKeyNotFound, kid)
+}
+
+func (ks *keyStore) serveJWKS(c *gin.Context) {
+	ks.mu.RLock()
+	set := jwks.Set{Keys: make([]jwks.Key, 0, len(ks.keys))}
+	for _, key := range ks.keys {
+		jwk, err := jwks.NewKey(key.kid, key.method.Alg(), key.private.Public())
+		if err != nil {
+			continue
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+	ks.mu.RUnlock()
+
+	c.Header("Cache-Control", fmt.Sprintf("public, max-age=%d", int(ks.publishAhead.Seconds()/2)))
+	c.JSON(http.StatusOK, set)
+}
+
+// keyCipher encrypts private keys at rest with a secret only auth-service
+// holds. The database is shared with services that verify tokens, and a key
+// they could read would let them sign tokens too.
+type keyCipher struct {
+	aead cipher.AEAD
+}
+
+func newKeyCipher(secret string) (*keyCipher, error) {
+	key, err := base64.StdEncoding.DecodeString(secret)
+	if err != nil || len(key) != 32 {
+		return nil, errors.New("JWT_KEY_ENCRYPTION_KEY must be 32 random bytes, base64 encoded")
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &keyCipher{aead: aead}, nil
+}
+
+// seal encrypts der with AES-GCM, prefixed by the nonce. The kid is bound
+// in as additional data so a ciphertext cannot be moved to another row.
+func (kc *keyCipher) seal(kid string, der []byte) ([]byte, error) {
+	nonce := make([]byte, kc.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return kc.aead.Seal(nonce, nonce, der, []byte(kid)), nil
+}
+
+func (kc *keyCipher) open(kid string, sealed []byte) ([]byte, error) {
+	size := kc.aead.NonceSize()
+	if len(sealed) < size {
+		return nil, errors.New("encrypted key is truncated")
+	}
+	der, err := kc.aead.Open(nil, sealed[:size], sealed[size:], []byte(kid))
+	if err != nil {
+		return nil, errors.New("cannot decrypt key, is JWT_KEY_ENCRYPTION_KEY right?")
+	}
+	return der, nil
+}
+
+func signingMethod(alg string) (jwt.SigningMethod, error) {
+	switch alg {
+	case "RS256":
+		return jwt.SigningMethodRS256, nil
+	case "EdDSA":
+		return jwt.SigningMethodEdDSA, nil
+	}
+	return nil, fmt.Errorf("unsupported JWT signing algorithm %q", alg)
+}
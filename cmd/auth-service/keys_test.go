@@ -0,0 +1,49 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+const testEncryptionKey = "INydNRKZNh51oit1VGTzcEtEZ4r3drczh392uD3qz70="
+
+func TestKeyCipher(t *testing.T) {
+	kc, err := newKeyCipher(testEncryptionKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	der := []byte("private key bytes")
+	sealed, err := kc.seal("kid-1", der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(sealed, der) {
+		t.Fatal("sealed key contains the plain key")
+	}
+
+	opened, err := kc.open("kid-1", sealed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(opened, der) {
+		t.Errorf("open() = %q, want %q", opened, der)
+	}
+
+	if _, err := kc.open("kid-2", sealed); err == nil {
+		t.Error("a key sealed for one kid opened for another")
+	}
+
+	other, _ := newKeyCipher("AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA=")
+	if _, err := other.open("kid-1", sealed); err == nil {
+		t.Error("a key opened with the wrong secret")
+	}
+}
+
+func TestNewKeyCipherRejectsBadSecrets(t *testing.T) {
+	for _, secret := range []string{"", "not base64!", "c2hvcnQ="} {
+		if _, err := newKeyCipher(secret); err == nil {
+			t.Errorf("newKeyCipher(%q) accepted the secret", secret)
+		}
+	}
+}
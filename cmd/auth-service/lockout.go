@@ -0,0 +1,203 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/crypto/bcrypt"
+
+	"news-aggregator/pkg/middleware"
+	"news-aggregator/pkg/models"
+)
+
+const maxLoginDelay = 30 * time.Second
+
+// dummyHash is compared against when the username does not exist, so a
+// failed login costs the same bcrypt time either way.
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("not-a-real-password"), bcrypt.DefaultCost)
+
+// Failures are counted per submitted username, whether or not it exists, and
+// per client IP. Each failure on a username adds a growing delay before the
+// next attempt; reaching the limit locks it for the lockout period.
+func loginKey(kind, scope, value string) string {
+	return fmt.Sprintf("login_%s:%s:%s", kind, scope, value)
+}
+
+func normalizeUsername(username string) string {
+	return strings.ToLower(strings.TrimSpace(username))
+}
+
+// loginRetryAfter returns how long the caller must wait before another
+// attempt, or zero. Redis errors let the attempt through; the password check
+// still applies.
+func (s *AuthService) loginRetryAfter(ctx context.Context, username, ip string) time.Duration {
+	username = normalizeUsername(username)
+
+	pipe := s.redis.Pipeline()
+	ttls := []*redis.DurationCmd{
+		pipe.PTTL(ctx, loginKey("lock", "user", username)),
+		pipe.PTTL(ctx, loginKey("delay", "user", username)),
+		pipe.PTTL(ctx, loginKey("lock", "ip", ip)),
+	}
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		log.Printf("Login throttle check failed: %v", err)
+		return 0
+	}
+
+	var wait time.Duration
+	for _, ttl := range ttls {
+		if ttl.Val() > wait {
+			wait = ttl.Val()
+		}
+	}
+	return wait
+}
+
+func (s *AuthService) recordLoginFailure(ctx context.Context, username, ip string) {
+	username = normalizeUsername(username)
+	window := time.Duration(s.config.LoginFailureWindowMinutes) * time.Minute
+	lockout := time.Duration(s.config.LoginLockoutMinutes) * time.Minute
+
+	pipe := s.redis.Pipeline()
+	userFailures := pipe.Incr(ctx, loginKey("fail", "user", username))
+	pipe.Expire(ctx, loginKey("fail", "user", username), window)
+	ipFailures := pipe.Incr(ctx, loginKey("fail", "ip", ip))
+	pipe.Expire(ctx, loginKey("fail", "ip", ip), window)
+	if _, err := pipe.Exec(ctx); err != nil {
+		log.Printf("Failed to record login failure: %v", err)
+		return
+	}
+
+	failures := int(userFailures.Val())
+	switch {
+	case failures >= s.config.LoginMaxFailures:
+		s.redis.Set(ctx, loginKey("lock", "user", username), 1, lockout)
+		s.redis.Del(ctx, loginKey("fail", "user", username))
+		s.audit(models.AuditLog{
+			Event:    models.AuditLoginLocked,
+			Username: username,
+			IP:       ip,
+			Details:  fmt.Sprintf("%d failed attempts, locked for %s", failures, lockout),
+		})
+	case failures >= 2:
+		delay := time.Second << (failures - 2)
+		if delay > maxLoginDelay {
+			delay = maxLoginDelay
+		}
+		s.redis.Set(ctx, loginKey("delay", "user", username), 1, delay)
+	}
+
+	if int(ipFailures.Val()) >= s.config.LoginIPMaxFailures {
+		s.redis.Set(ctx, loginKey("lock", "ip", ip), 1, lockout)
+		s.redis.Del(ctx, loginKey("fail", "ip", ip))
+		s.audit(models.AuditLog{
+			Event:   models.AuditLoginLocked,
+			IP:      ip,
+			Details: fmt.Sprintf("%d failed attempts from IP, locked for %s", ipFailures.Val(), lockout),
+		})
+	}
+}
+
+// clearLoginFailures resets the username's counters after a successful
+// login. The IP counter is left alone so one valid account cannot be used to
+// keep resetting it.
+func (s *AuthService) clearLoginFailures(ctx context.Context, username string) {
+	username = normalizeUsername(username)
+	if err := s.redis.Del(ctx,
+		loginKey("fail", "user", username),
+		loginKey("delay", "user", username),
+	).Err(); err != nil {
+		log.Printf("Failed to clear login failures: %v", err)
+	}
+}
+
+func (s *AuthService) tooManyAttempts(c *gin.Context, wait time.Duration) {
+	seconds := int((wait + time.Second - 1) / time.Second)
+	c.Header("Retry-After", strconv.Itoa(seconds))
+	c.JSON(http.StatusTooManyRequests, gin.H{
+		"error":       "Too many login attempts",
+		"retry_after": seconds,
+	})
+}
+
+// unlockUser lifts a login lockout on the account before it expires.
+func (s *AuthService) unlockUser(c *gin.Context) {
+	user, ok := s.findUser(c)
+	if !ok {
+		return
+	}
+
+	username := normalizeUsername(user.Username)
+	if err := s.redis.Del(c.Request.Context(),
+		loginKey("lock", "user", username),
+		loginKey("fail", "user", username),
+		loginKey("delay", "user", username),
+	).Err(); err != nil {
+		log.Printf("Failed to unlock user %d: %v", user.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unlock user"})
+		return
+	}
+
+	actorID, _ := middleware.UserID(c)
+	s.audit(models.AuditLog{
+		Event:    models.AuditLoginUnlocked,
+		UserID:   &user.ID,
+		ActorID:  &actorID,
+		Username: username,
+		IP:       c.ClientIP(),
+	})
+
+	c.JSON(http.StatusOK, gin.H{"message": "User unlocked"})
+}
+
+func (s *AuthService) listAuditLogs(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 200 {
+		limit = 50
+	}
+
+	offset := (page - 1) * limit
+
+	query := s.db.Model(&models.AuditLog{})
+	if event := c.Query("event"); event != "" {
+		query = query.Where("event = ?", event)
+	}
+	if userID := c.Query("user_id"); userID != "" {
+		query = query.Where("user_id = ?", userID)
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var logs []models.AuditLog
+	if err := query.Order("id DESC").Limit(limit).Offset(offset).Find(&logs).Error; err != nil {
+		log.Printf("Failed to fetch audit logs: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch audit logs"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":  logs,
+		"total": total,
+		"page":  page,
+		"limit": limit,
+	})
+}
+
+func (s *AuthService) audit(entry models.AuditLog) {
+	if err := s.db.Create(&entry).Error; err != nil {
+		log.Printf("Failed to write audit log %s: %v", entry.Event, err)
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+
+	"news-aggregator/pkg/middleware"
+	"news-aggregator/pkg/models"
+)
+
+// logout revokes the access token used for the request and ends its
+// session. Tokens from before sessions existed carry no sid; for those the
+// refresh token may be given instead.
+func (s *AuthService) logout(c *gin.Context) {
+	var req models.LogoutRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	userID, _ := middleware.UserID(c)
+	claims := c.MustGet("claims").(jwt.MapClaims)
+	jti, _ := claims["jti"].(string)
+	expiresAt := time.Now().Add(s.accessTTL())
+	if exp, err := claims.GetExpirationTime(); err == nil && exp != nil {
+		expiresAt = exp.Time
+	}
+
+	if err := s.revocation.Revoke(c.Request.Context(), jti, expiresAt); err != nil {
+		log.Printf("Failed to revoke token for user %d: %v", userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to logout"})
+		return
+	}
+
+	if sid, _ := claims["sid"].(string); sid != "" {
+		s.revokeFamily(sid)
+	} else if req.RefreshToken != "" {
+		var record models.RefreshToken
+		if err := s.db.Where("token_hash = ? AND user_id = ?", hashToken(req.RefreshToken), userID).
+			First(&record).Error; err == nil {
+			s.revokeFamily(record.FamilyID)
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out successfully"})
+}
+
+// logoutAll invalidates every access and refresh token the user holds.
+func (s *AuthService) logoutAll(c *gin.Context) {
+	userID, ok := middleware.UserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+		return
+	}
+
+	if err := s.revocation.RevokeUser(c.Request.Context(), userID, s.accessTTL()); err != nil {
+		log.Printf("Failed to revoke tokens for user %d: %v", userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to logout"})
+		return
+	}
+
+	if err := revokeAllSessions(s.db, userID); err != nil {
+		log.Printf("Failed to revoke sessions for user %d: %v", userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to logout"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out from all devices"})
+}
@@ -13,14 +13,25 @@ import (
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 
+	"news-aggregator/pkg/apikey"
 	"news-aggregator/pkg/config"
+	"news-aggregator/pkg/mailer"
+	"news-aggregator/pkg/metrics"
+	"news-aggregator/pkg/middleware"
 	"news-aggregator/pkg/models"
+	"news-aggregator/pkg/rbac"
+	"news-aggregator/pkg/revocation"
 )
 
 type AuthService struct {
-	db     *gorm.DB
-	redis  *redis.Client
-	config *config.Config
+	db         *gorm.DB
+	redis      *redis.Client
+	keys       *keyStore
+	revocation *revocation.Store
+	apiKeys    *apikey.Store
+	mailer     mailer.Mailer
+	oidc       map[string]*oidcProvider
+	config     *config.Config
 }
 
 func main() {
@@ -33,27 +44,78 @@ func main() {
 	}
 
 	// Auto migrate
-	db.AutoMigrate(&models.User{})
+	db.AutoMigrate(&models.User{}, &models.RefreshToken{}, &models.Session{}, &models.SigningKey{}, &models.UserToken{}, &models.AuditLog{}, &models.RecoveryCode{}, &models.APIKey{}, &models.UserIdentity{})
+
+	if err := bootstrapAdmin(db, cfg); err != nil {
+		log.Fatal("Failed to bootstrap admin account:", err)
+	}
+
+	// Signing keys
+	accessTTL := time.Duration(cfg.JWTAccessTTLMinutes) * time.Minute
+	jwksCache := time.Duration(cfg.JWKSCacheMinutes) * time.Minute
+	keys, err := newKeyStore(db, cfg.JWTKeyEncryptionKey, cfg.JWTSigningAlg,
+		time.Duration(cfg.JWTKeyRotationHours)*time.Hour,
+		2*jwksCache,
+		accessTTL+time.Minute)
+	if err != nil {
+		log.Fatal("Failed to load signing keys:", err)
+	}
+	go keys.run()
+
+	mail, err := mailer.New(mailer.Config{
+		Driver:   cfg.MailDriver,
+		From:     cfg.MailFrom,
+		SMTPHost: cfg.SMTPHost,
+		SMTPPort: cfg.SMTPPort,
+		Username: cfg.SMTPUsername,
+		Password: cfg.SMTPPassword,
+		Dir:      cfg.MailDir,
+	})
+	if err != nil {
+		log.Fatal("Failed to set up mailer:", err)
+	}
+
+	providers, err := loadOIDCProviders(cfg.OIDCProvidersFile, cfg.OIDCRedirectBaseURL)
+	if err != nil {
+		log.Fatal("Failed to load OIDC providers:", err)
+	}
 
 	// Redis connection
 	rdb := redis.NewClient(&redis.Options{
 		Addr: cfg.RedisURL,
 	})
 
+	if err := metrics.RegisterDB(db, "postgres"); err != nil {
+		log.Printf("Failed to register database metrics: %v", err)
+	}
+	if err := metrics.RegisterRedis(rdb); err != nil {
+		log.Printf("Failed to register Redis metrics: %v", err)
+	}
+
 	service := &AuthService{
-		db:     db,
-		redis:  rdb,
-		config: cfg,
+		db:         db,
+		redis:      rdb,
+		keys:       keys,
+		revocation: revocation.New(rdb),
+		apiKeys:    apikey.New(rdb),
+		mailer:     mail,
+		oidc:       providers,
+		config:     cfg,
 	}
 
 	router := gin.Default()
+	// Client IPs key the login lockout, so only the gateway may vouch for them
+	if err := router.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		log.Fatal("Invalid TRUSTED_PROXIES:", err)
+	}
+	router.Use(metrics.Middleware())
 
 	// Add simple CORS middleware that actually works
 	router.Use(func(c *gin.Context) {
 		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
 		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With")
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, DELETE")
+		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, X-API-Key, accept, origin, Cache-Control, X-Requested-With")
+		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, PATCH, DELETE")
 
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(http.StatusNoContent)
@@ -63,13 +125,18 @@ func main() {
 		c.Next()
 	})
 
-	service.setupRoutes(router)
+	auth := middleware.NewAuthMiddleware(keys.keyfunc, rdb)
+	service.setupRoutes(router, auth)
+
+	go service.cleanupTokens()
+	go service.resyncAPIKeys()
+	go service.purgeDeletedUsers()
 
 	fmt.Printf("Auth service starting on port %s\n", cfg.AuthServicePort)
 	log.Fatal(http.ListenAndServe(":"+cfg.AuthServicePort, router))
 }
 
-func (s *AuthService) setupRoutes(router *gin.Engine) {
+func (s *AuthService) setupRoutes(router *gin.Engine, auth *middleware.AuthMiddleware) {
 
 	// Health check endpoint
 	router.GET("/health", func(c *gin.Context) {
@@ -80,6 +147,11 @@ func (s *AuthService) setupRoutes(router *gin.Engine) {
 		})
 	})
 
+	router.GET("/metrics", gin.WrapH(metrics.Handler()))
+
+	// Public keys for verifying access tokens
+	router.GET("/.well-known/jwks.json", s.keys.serveJWKS)
+
 	// OPTIONS handler for all routes
 	router.OPTIONS("/*path", func(c *gin.Context) {
 		c.Status(http.StatusNoContent)
@@ -89,7 +161,55 @@ func (s *AuthService) setupRoutes(router *gin.Engine) {
 	{
 		api.POST("/register", s.register)
 		api.POST("/login", s.login)
+		api.POST("/login/mfa", s.loginMFA)
 		api.POST("/verify", s.verifyToken)
+		api.POST("/refresh", s.refresh)
+		api.POST("/forgot-password", s.forgotPassword)
+		api.POST("/reset-password", s.resetPassword)
+		api.POST("/verify-email", s.verifyEmail)
+		api.GET("/oidc/providers", s.listOIDCProviders)
+		api.GET("/oidc/:provider/login", s.oidcLogin)
+		api.GET("/oidc/:provider/callback", s.oidcCallback)
+
+		protected := api.Group("")
+		protected.Use(auth.JWTAuth(), middleware.SessionOnly())
+		{
+			protected.POST("/logout", s.logout)
+			protected.POST("/logout-all", s.logoutAll)
+			protected.POST("/verify-email/resend", s.resendVerification)
+			protected.POST("/mfa/totp/enroll", s.enrollTOTP)
+			protected.POST("/mfa/totp/confirm", s.confirmTOTP)
+			protected.POST("/mfa/totp/disable", s.disableTOTP)
+			protected.POST("/mfa/recovery-codes", s.regenerateRecoveryCodes)
+			protected.POST("/api-keys", s.createAPIKey)
+			protected.GET("/api-keys", s.listAPIKeys)
+			protected.DELETE("/api-keys/:id", s.revokeAPIKey)
+		}
+
+		me := api.Group("/me")
+		me.Use(auth.JWTAuth(), middleware.SessionOnly())
+		{
+			me.GET("", s.getMe)
+			me.PATCH("", s.updateMe)
+			me.DELETE("", s.deleteMe)
+			me.POST("/password", s.changePassword)
+			me.GET("/export", s.exportMe)
+			me.GET("/sessions", s.listSessions)
+			me.DELETE("/sessions/:id", s.deleteSession)
+		}
+
+		admin := api.Group("/admin")
+		admin.Use(auth.JWTAuth(), middleware.RequirePermission(rbac.PermUsersAdmin))
+		{
+			admin.GET("/users", s.listUsers)
+			admin.GET("/users/:id", s.getUser)
+			admin.PUT("/users/:id/role", s.updateUserRole)
+			admin.POST("/users/:id/suspend", s.suspendUser)
+			admin.POST("/users/:id/reactivate", s.reactivateUser)
+			admin.POST("/users/:id/unlock", s.unlockUser)
+			admin.GET("/audit-logs", s.listAuditLogs)
+			admin.PUT("/api-keys/:id/quota", s.updateAPIKeyQuota)
+		}
 
 	}
 }
@@ -101,9 +221,10 @@ func (s *AuthService) register(c *gin.Context) {
 		return
 	}
 
-	// Check if user exists
+	// Check if user exists; deleted accounts hold their name and address until purged
+	email := normalizeEmail(req.Email)
 	var existingUser models.User
-	if err := s.db.Where("username = ? OR email = ?", req.Username, req.Email).First(&existingUser).Error; err == nil {
+	if err := s.db.Unscoped().Where("username = ? OR LOWER(email) = ?", req.Username, email).First(&existingUser).Error; err == nil {
 		c.JSON(http.StatusConflict, gin.H{"error": "User already exists"})
 		return
 	}
@@ -118,9 +239,10 @@ func (s *AuthService) register(c *gin.Context) {
 	// Create user
 	user := models.User{
 		Username: req.Username,
-		Email:    req.Email,
+		Email:    email,
 		Password: string(hashedPassword),
-		Role:     "user",
+		Role:     rbac.RoleUser,
+		Status:   models.UserStatusActive,
 	}
 
 	if err := s.db.Create(&user).Error; err != nil {
@@ -128,17 +250,19 @@ func (s *AuthService) register(c *gin.Context) {
 		return
 	}
 
-	// Generate token
-	token, err := s.generateToken(user)
+	// The account works right away; verification only proves the address
+	if err := s.sendVerification(user); err != nil {
+		log.Printf("Failed to send verification email to user %d: %v", user.ID, err)
+	}
+
+	// Generate tokens
+	resp, err := s.issueTokens(c, user)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
 		return
 	}
 
-	c.JSON(http.StatusCreated, models.AuthResponse{
-		Token: token,
-		User:  user,
-	})
+	c.JSON(http.StatusCreated, resp)
 }
 
 func (s *AuthService) login(c *gin.Context) {
@@ -148,30 +272,48 @@ func (s *AuthService) login(c *gin.Context) {
 		return
 	}
 
+	ctx := c.Request.Context()
+	if wait := s.loginRetryAfter(ctx, req.Username, c.ClientIP()); wait > 0 {
+		s.tooManyAttempts(c, wait)
+		return
+	}
+
 	// Find user
 	var user models.User
 	if err := s.db.Where("username = ?", req.Username).First(&user).Error; err != nil {
+		bcrypt.CompareHashAndPassword(dummyHash, []byte(req.Password))
+		s.recordLoginFailure(ctx, req.Username, c.ClientIP())
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
 		return
 	}
 
 	// Check password
 	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
+		s.recordLoginFailure(ctx, req.Username, c.ClientIP())
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
 		return
 	}
 
-	// Generate token
-	token, err := s.generateToken(user)
+	if user.Status == models.UserStatusSuspended {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Account suspended"})
+		return
+	}
+
+	// With two factors the failures stand until the code is right too
+	if user.TOTPEnabled {
+		s.startMFAChallenge(c, user)
+		return
+	}
+	s.clearLoginFailures(ctx, req.Username)
+
+	// Generate tokens
+	resp, err := s.issueTokens(c, user)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
 		return
 	}
 
-	c.JSON(http.StatusOK, models.AuthResponse{
-		Token: token,
-		User:  user,
-	})
+	c.JSON(http.StatusOK, resp)
 }
 
 func (s *AuthService) verifyToken(c *gin.Context) {
@@ -186,13 +328,7 @@ func (s *AuthService) verifyToken(c *gin.Context) {
 		tokenString = tokenString[7:]
 	}
 
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		// Validate signing method
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return []byte(s.config.JWTSecret), nil
-	})
+	token, err := jwt.Parse(tokenString, s.keys.keyfunc, jwt.WithValidMethods(middleware.SigningMethods))
 
 	if err != nil {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Token parsing failed: " + err.Error()})
@@ -205,6 +341,20 @@ func (s *AuthService) verifyToken(c *gin.Context) {
 	}
 
 	if claims, ok := token.Claims.(jwt.MapClaims); ok {
+		userID, _ := claims["userID"].(float64)
+		jti, _ := claims["jti"].(string)
+		sid, _ := claims["sid"].(string)
+
+		revoked, err := s.revocation.IsRevoked(c.Request.Context(), jti, sid, uint(userID), revocation.IssuedAt(claims))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Token revocation check failed"})
+			return
+		}
+		if revoked {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Token revoked"})
+			return
+		}
+
 		c.JSON(http.StatusOK, gin.H{
 			"valid":    true,
 			"userID":   claims["userID"],
@@ -215,15 +365,3 @@ func (s *AuthService) verifyToken(c *gin.Context) {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token claims"})
 	}
 }
-
-func (s *AuthService) generateToken(user models.User) (string, error) {
-	claims := jwt.MapClaims{
-		"userID":   user.ID,
-		"username": user.Username,
-		"role":     user.Role,
-		"exp":      time.Now().Add(time.Hour * time.Duration(s.config.JWTExpireHours)).Unix(),
-	}
-
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(s.config.JWTSecret))
-}
@@ -0,0 +1,387 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+
+	"news-aggregator/pkg/middleware"
+	"news-aggregator/pkg/models"
+	"news-aggregator/pkg/totp"
+)
+
+const (
+	recoveryCodeCount   = 10
+	maxMFAAttempts      = 5
+	totpSkew            = 1
+	recoveryCodeEncoded = 10
+)
+
+func mfaChallengeKey(token string) string {
+	return fmt.Sprintf("mfa_challenge:%s", hashToken(token))
+}
+
+func mfaAttemptsKey(token string) string {
+	return fmt.Sprintf("mfa_attempts:%s", hashToken(token))
+}
+
+func (s *AuthService) enrollTOTP(c *gin.Context) {
+	user, ok := s.currentUser(c)
+	if !ok {
+		return
+	}
+	if user.TOTPEnabled {
+		c.JSON(http.StatusConflict, gin.H{"error": "Two-factor authentication already enabled"})
+		return
+	}
+
+	// Kept pending until confirmed with a code from the app
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start enrollment"})
+		return
+	}
+	if err := s.db.Model(&user).Update("totp_secret", secret).Error; err != nil {
+		log.Printf("Failed to save TOTP secret for user %d: %v", user.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start enrollment"})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.TOTPEnrollResponse{
+		Secret:          secret,
+		ProvisioningURI: totp.URI(secret, s.config.TOTPIssuer, user.Username),
+	})
+}
+
+func (s *AuthService) confirmTOTP(c *gin.Context) {
+	var req models.TOTPCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, ok := s.currentUser(c)
+	if !ok {
+		return
+	}
+	if user.TOTPEnabled {
+		c.JSON(http.StatusConflict, gin.H{"error": "Two-factor authentication already enabled"})
+		return
+	}
+	if user.TOTPSecret == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Enrollment not started"})
+		return
+	}
+
+	step, valid := totp.Validate(user.TOTPSecret, req.Code, time.Now(), totpSkew)
+	if !valid {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid code"})
+		return
+	}
+
+	var codes []string
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&user).Updates(map[string]interface{}{
+			"totp_enabled":   true,
+			"totp_last_step": step,
+		}).Error; err != nil {
+			return err
+		}
+
+		var err error
+		codes, err = replaceRecoveryCodes(tx, user.ID)
+		return err
+	})
+	if err != nil {
+		log.Printf("Failed to enable TOTP for user %d: %v", user.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to enable two-factor authentication"})
+		return
+	}
+
+	s.audit(models.AuditLog{Event: models.AuditMFAEnabled, UserID: &user.ID, Username: user.Username, IP: c.ClientIP()})
+
+	c.JSON(http.StatusOK, models.RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+func (s *AuthService) disableTOTP(c *gin.Context) {
+	var req models.TOTPDisableRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, ok := s.currentUser(c)
+	if !ok {
+		return
+	}
+	if !user.TOTPEnabled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Two-factor authentication is not enabled"})
+		return
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+		return
+	}
+	if ok, err := s.checkSecondFactor(user, req.Code); err != nil || !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid code"})
+		return
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&user).Updates(map[string]interface{}{
+			"totp_enabled":   false,
+			"totp_secret":    "",
+			"totp_last_step": 0,
+		}).Error; err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", user.ID).Delete(&models.RecoveryCode{}).Error
+	})
+	if err != nil {
+		log.Printf("Failed to disable TOTP for user %d: %v", user.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to disable two-factor authentication"})
+		return
+	}
+
+	s.audit(models.AuditLog{Event: models.AuditMFADisabled, UserID: &user.ID, Username: user.Username, IP: c.ClientIP()})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication disabled"})
+}
+
+// regenerateRecoveryCodes replaces all recovery codes, e.g. after the user
+// has used up most of them.
+func (s *AuthService) regenerateRecoveryCodes(c *gin.Context) {
+	var req models.TOTPCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, ok := s.currentUser(c)
+	if !ok {
+		return
+	}
+	if !user.TOTPEnabled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Two-factor authentication is not enabled"})
+		return
+	}
+	if ok, err := s.checkTOTP(user, req.Code); err != nil || !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid code"})
+		return
+	}
+
+	var codes []string
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		codes, err = replaceRecoveryCodes(tx, user.ID)
+		return err
+	})
+	if err != nil {
+		log.Printf("Failed to regenerate recovery codes for user %d: %v", user.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to regenerate recovery codes"})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// startMFAChallenge parks a password-verified login until the second factor
+// arrives at /login/mfa.
+func (s *AuthService) startMFAChallenge(c *gin.Context, user models.User) {
+	challenge, err := s.newMFAChallenge(c.Request.Context(), user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start login"})
+		return
+	}
+
+	c.JSON(http.StatusOK, challenge)
+}
+
+func (s *AuthService) newMFAChallenge(ctx context.Context, user models.User) (models.MFAChallengeResponse, error) {
+	token, err := newOpaqueToken()
+	if err != nil {
+		return models.MFAChallengeResponse{}, err
+	}
+
+	ttl := time.Duration(s.config.MFAChallengeTTLMinutes) * time.Minute
+	if err := s.redis.Set(ctx, mfaChallengeKey(token), user.ID, ttl).Err(); err != nil {
+		log.Printf("Failed to store MFA challenge for user %d: %v", user.ID, err)
+		return models.MFAChallengeResponse{}, err
+	}
+
+	return models.MFAChallengeResponse{
+		MFARequired: true,
+		MFAToken:    token,
+		ExpiresIn:   int64(ttl.Seconds()),
+	}, nil
+}
+
+func (s *AuthService) loginMFA(c *gin.Context) {
+	var req models.MFALoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx := c.Request.Context()
+	userID, err := s.redis.Get(ctx, mfaChallengeKey(req.MFAToken)).Uint64()
+	if err != nil {
+		if err != redis.Nil {
+			log.Printf("Failed to load MFA challenge: %v", err)
+		}
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired MFA token"})
+		return
+	}
+
+	var user models.User
+	if err := s.db.Where("id = ?", userID).First(&user).Error; err != nil || user.Status == models.UserStatusSuspended {
+		s.redis.Del(ctx, mfaChallengeKey(req.MFAToken))
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired MFA token"})
+		return
+	}
+
+	// Wrong codes count against the same lockout as wrong passwords
+	if wait := s.loginRetryAfter(ctx, user.Username, c.ClientIP()); wait > 0 {
+		s.tooManyAttempts(c, wait)
+		return
+	}
+
+	ok, err := s.checkSecondFactor(user, req.Code)
+	if err != nil {
+		log.Printf("Failed to check second factor for user %d: %v", user.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify code"})
+		return
+	}
+	if !ok {
+		s.countMFAFailure(ctx, req.MFAToken)
+		s.recordLoginFailure(ctx, user.Username, c.ClientIP())
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid code"})
+		return
+	}
+
+	// Single use: a second request with the same challenge fails
+	if n, err := s.redis.Del(ctx, mfaChallengeKey(req.MFAToken), mfaAttemptsKey(req.MFAToken)).Result(); err != nil || n == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired MFA token"})
+		return
+	}
+	s.clearLoginFailures(ctx, user.Username)
+
+	resp, err := s.issueTokens(c, user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// countMFAFailure drops the challenge after too many wrong codes, sending the
+// user back through the password step. Each wrong code is also recorded as a
+// login failure, so fresh challenges do not reset the budget.
+func (s *AuthService) countMFAFailure(ctx context.Context, token string) {
+	attempts, err := s.redis.Incr(ctx, mfaAttemptsKey(token)).Result()
+	if err != nil {
+		return
+	}
+	s.redis.Expire(ctx, mfaAttemptsKey(token), time.Duration(s.config.MFAChallengeTTLMinutes)*time.Minute)
+
+	if attempts >= maxMFAAttempts {
+		s.redis.Del(ctx, mfaChallengeKey(token), mfaAttemptsKey(token))
+	}
+}
+
+// checkSecondFactor accepts a current TOTP code or an unused recovery code.
+func (s *AuthService) checkSecondFactor(user models.User, code string) (bool, error) {
+	code = strings.TrimSpace(code)
+	if _, err := strconv.Atoi(code); err == nil && len(code) == totp.Digits {
+		return s.checkTOTP(user, code)
+	}
+
+	result := s.db.Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", user.ID, hashToken(normalizeRecoveryCode(code))).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return false, nil
+	}
+
+	s.audit(models.AuditLog{Event: models.AuditMFARecoveryUsed, UserID: &user.ID, Username: user.Username})
+	return true, nil
+}
+
+// checkTOTP validates a code and records its step, so each code works once
+// even though it stays valid for the whole window.
+func (s *AuthService) checkTOTP(user models.User, code string) (bool, error) {
+	step, valid := totp.Validate(user.TOTPSecret, code, time.Now(), totpSkew)
+	if !valid {
+		return false, nil
+	}
+
+	result := s.db.Model(&models.User{}).
+		Where("id = ? AND totp_last_step < ?", user.ID, step).
+		Update("totp_last_step", step)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// replaceRecoveryCodes deletes the user's recovery codes and returns a fresh
+// set, which is shown once and stored only as hashes.
+func replaceRecoveryCodes(tx *gorm.DB, userID uint) ([]string, error) {
+	if err := tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+		return nil, err
+	}
+
+	codes := make([]string, recoveryCodeCount)
+	records := make([]models.RecoveryCode, recoveryCodeCount)
+	for i := range codes {
+		raw := make([]byte, 8)
+		if _, err := rand.Read(raw); err != nil {
+			return nil, err
+		}
+		encoded := strings.ToLower(base32.StdEncoding.EncodeToString(raw))[:recoveryCodeEncoded]
+		codes[i] = encoded[:5] + "-" + encoded[5:]
+		records[i] = models.RecoveryCode{UserID: userID, CodeHash: hashToken(encoded)}
+	}
+
+	if err := tx.Create(&records).Error; err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+}
+
+// currentUser loads the authenticated user, writing the error response
+// itself when it cannot.
+func (s *AuthService) currentUser(c *gin.Context) (models.User, bool) {
+	var user models.User
+	userID, ok := middleware.UserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+		return user, false
+	}
+
+	if err := s.db.Where("id = ?", userID).First(&user).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return user, false
+	}
+	return user, true
+}
@@ -0,0 +1,558 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/oauth2"
+	"gopkg.in/yaml.v3"
+	"gorm.io/gorm"
+
+	"news-aggregator/pkg/models"
+	"news-aggregator/pkg/rbac"
+)
+
+// oidcStateTTL bounds how long a user may spend at the identity provider.
+const oidcStateTTL = 10 * time.Minute
+
+var (
+	errOIDCNoEmail       = errors.New("identity provider returned no email")
+	errOIDCEmailConflict = errors.New("email belongs to an existing account")
+	errOIDCUnverified    = errors.New("existing account has an unverified email")
+)
+
+// oidcProviderConfig is one entry of the providers file. Values may refer to
+// environment variables as ${NAME}, which keeps client secrets out of it.
+type oidcProviderConfig struct {
+	Name         string   `yaml:"name"`
+	DisplayName  string   `yaml:"display_name"`
+	Issuer       string   `yaml:"issuer"`
+	ClientID     string   `yaml:"client_id"`
+	ClientSecret string   `yaml:"client_secret"`
+	RedirectURL  string   `yaml:"redirect_url"`
+	Scopes       []string `yaml:"scopes"`
+	// TrustEmail treats the email claim as verified for providers that
+	// never send email_verified. Only for providers that verify every
+	// address themselves: it lets the provider claim existing accounts.
+	TrustEmail bool `yaml:"trust_email"`
+	Claims     struct {
+		Username string `yaml:"username"`
+		Email    string `yaml:"email"`
+		Role     string `yaml:"role"`
+	} `yaml:"claims"`
+	// Roles maps values of the role claim to our roles
+	Roles map[string]string `yaml:"roles"`
+}
+
+type oidcProvider struct {
+	config oidcProviderConfig
+
+	mu       sync.Mutex
+	provider *oidc.Provider
+	verifier *oidc.IDTokenVerifier
+	oauth2   oauth2.Config
+}
+
+// oidcState is kept in Redis between redirecting the user to the provider
+// and the provider sending them back.
+type oidcState struct {
+	Provider string `json:"provider"`
+	Nonce    string `json:"nonce"`
+	Verifier string `json:"verifier"`
+	ReturnTo string `json:"return_to,omitempty"`
+}
+
+func loadOIDCProviders(path, redirectBase string) (map[string]*oidcProvider, error) {
+	providers := make(map[string]*oidcProvider)
+	if path == "" {
+		return providers, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var file struct {
+		Providers []oidcProviderConfig `yaml:"providers"`
+	}
+	if err := yaml.Unmarshal([]byte(os.ExpandEnv(string(data))), &file); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+
+	for _, cfg := range file.Providers {
+		if cfg.Name == "" || cfg.Issuer == "" || cfg.ClientID == "" {
+			return nil, fmt.Errorf("provider %q: name, issuer and client_id are required", cfg.Name)
+		}
+		if _, ok := providers[cfg.Name]; ok {
+			return nil, fmt.Errorf("provider %q defined twice", cfg.Name)
+		}
+		for claimValue, role := range cfg.Roles {
+			if !rbac.ValidRole(role) {
+				return nil, fmt.Errorf("provider %q: unknown role %q for %q", cfg.Name, role, claimValue)
+			}
+		}
+
+		if cfg.DisplayName == "" {
+			cfg.DisplayName = cfg.Name
+		}
+		if cfg.RedirectURL == "" {
+			cfg.RedirectURL = strings.TrimRight(redirectBase, "/") + "/api/v1/auth/oidc/" + cfg.Name + "/callback"
+		}
+		if len(cfg.Scopes) == 0 {
+			cfg.Scopes = []string{oidc.ScopeOpenID, "profile", "email"}
+		}
+		if cfg.Claims.Username == "" {
+			cfg.Claims.Username = "preferred_username"
+		}
+		if cfg.Claims.Email == "" {
+			cfg.Claims.Email = "email"
+		}
+
+		providers[cfg.Name] = &oidcProvider{config: cfg}
+	}
+
+	return providers, nil
+}
+
+// discover fetches the provider's metadata on first use, so an identity
+// provider that is down does not stop the auth service from starting.
+func (p *oidcProvider) discover(ctx context.Context) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.provider != nil {
+		return nil
+	}
+
+	provider, err := oidc.NewProvider(ctx, p.config.Issuer)
+	if err != nil {
+		return err
+	}
+
+	scopes := p.config.Scopes
+	hasOpenID := false
+	for _, scope := range scopes {
+		hasOpenID = hasOpenID || scope == oidc.ScopeOpenID
+	}
+	if !hasOpenID {
+		scopes = append([]string{oidc.ScopeOpenID}, scopes...)
+	}
+
+	p.provider = provider
+	p.verifier = provider.Verifier(&oidc.Config{ClientID: p.config.ClientID})
+	p.oauth2 = oauth2.Config{
+		ClientID:     p.config.ClientID,
+		ClientSecret: p.config.ClientSecret,
+		Endpoint:     provider.Endpoint(),
+		RedirectURL:  p.config.RedirectURL,
+		Scopes:       scopes,
+	}
+	return nil
+}
+
+func oidcStateKey(state string) string {
+	return fmt.Sprintf("oidc_state:%s", state)
+}
+
+func (s *AuthService) listOIDCProviders(c *gin.Context) {
+	providers := make([]gin.H, 0, len(s.oidc))
+	for _, p := range s.oidc {
+		providers = append(providers, gin.H{
+			"name":         p.config.Name,
+			"display_name": p.config.DisplayName,
+			"login_url":    "/api/v1/auth/oidc/" + p.config.Name + "/login",
+		})
+	}
+	sort.Slice(providers, func(i, j int) bool {
+		return providers[i]["name"].(string) < providers[j]["name"].(string)
+	})
+
+	c.JSON(http.StatusOK, gin.H{"data": providers})
+}
+
+// oidcLogin sends the browser to the provider using the authorization code
+// flow with PKCE. return_to, if given, must be a page of the web app; the
+// callback redirects there with the tokens.
+func (s *AuthService) oidcLogin(c *gin.Context) {
+	p, ok := s.findOIDCProvider(c)
+	if !ok {
+		return
+	}
+
+	returnTo := c.Query("return_to")
+	if returnTo != "" && !s.validReturnTo(returnTo) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid return_to"})
+		return
+	}
+
+	if err := p.discover(c.Request.Context()); err != nil {
+		log.Printf("OIDC discovery failed for %s: %v", p.config.Name, err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Identity provider unavailable"})
+		return
+	}
+
+	state, err := newOpaqueToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start login"})
+		return
+	}
+	nonce, err := newOpaqueToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start login"})
+		return
+	}
+	verifier := oauth2.GenerateVerifier()
+
+	data, _ := json.Marshal(oidcState{
+		Provider: p.config.Name,
+		Nonce:    nonce,
+		Verifier: verifier,
+		ReturnTo: returnTo,
+	})
+	if err := s.redis.Set(c.Request.Context(), oidcStateKey(state), data, oidcStateTTL).Err(); err != nil {
+		log.Printf("Failed to store OIDC state: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start login"})
+		return
+	}
+
+	c.Redirect(http.StatusFound, p.oauth2.AuthCodeURL(state, oidc.Nonce(nonce), oauth2.S256ChallengeOption(verifier)))
+}
+
+func (s *AuthService) oidcCallback(c *gin.Context) {
+	p, ok := s.findOIDCProvider(c)
+	if !ok {
+		return
+	}
+
+	if errCode := c.Query("error"); errCode != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Login failed: " + errCode, "description": c.Query("error_description")})
+		return
+	}
+
+	// State is single use; a replayed callback finds nothing
+	ctx := c.Request.Context()
+	data, err := s.redis.GetDel(ctx, oidcStateKey(c.Query("state"))).Bytes()
+	var state oidcState
+	if err != nil || json.Unmarshal(data, &state) != nil || state.Provider != p.config.Name {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired login state"})
+		return
+	}
+
+	if err := p.discover(ctx); err != nil {
+		log.Printf("OIDC discovery failed for %s: %v", p.config.Name, err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Identity provider unavailable"})
+		return
+	}
+
+	token, err := p.oauth2.Exchange(ctx, c.Query("code"), oauth2.VerifierOption(state.Verifier))
+	if err != nil {
+		log.Printf("OIDC code exchange failed for %s: %v", p.config.Name, err)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Login failed"})
+		return
+	}
+
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Login failed: no ID token"})
+		return
+	}
+	idToken, err := p.verifier.Verify(ctx, rawIDToken)
+	if err != nil {
+		log.Printf("OIDC ID token rejected for %s: %v", p.config.Name, err)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Login failed"})
+		return
+	}
+	if idToken.Nonce != state.Nonce {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Login failed: nonce mismatch"})
+		return
+	}
+
+	claims := map[string]interface{}{}
+	if err := idToken.Claims(&claims); err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Login failed"})
+		return
+	}
+
+	// Some providers only put the email in the userinfo response
+	if claimString(claims, p.config.Claims.Email) == "" {
+		if info, err := p.provider.UserInfo(ctx, oauth2.StaticTokenSource(token)); err == nil {
+			var extra map[string]interface{}
+			if info.Claims(&extra) == nil {
+				for name, value := range extra {
+					if _, ok := claims[name]; !ok {
+						claims[name] = value
+					}
+				}
+			}
+		}
+	}
+
+	user, err := s.oidcUser(ctx, p, idToken.Subject, claims)
+	if err != nil {
+		switch err {
+		case errOIDCNoEmail:
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Identity provider did not share an email address"})
+		case errOIDCEmailConflict:
+			c.JSON(http.StatusConflict, gin.H{"error": "An account with this email already exists; sign in with your password first"})
+		case errOIDCUnverified:
+			c.JSON(http.StatusConflict, gin.H{"error": "An account with this email already exists but its email is not verified; sign in with your password and verify it first"})
+		default:
+			log.Printf("Failed to resolve OIDC user for %s: %v", p.config.Name, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Login failed"})
+		}
+		return
+	}
+
+	if user.Status == models.UserStatusSuspended {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Account suspended"})
+		return
+	}
+
+	// The provider may not ask for a second factor, so ours still applies
+	if user.TOTPEnabled {
+		challenge, err := s.newMFAChallenge(ctx, user)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start login"})
+			return
+		}
+		if state.ReturnTo == "" {
+			c.JSON(http.StatusOK, challenge)
+			return
+		}
+
+		fragment := url.Values{}
+		fragment.Set("mfa_required", "true")
+		fragment.Set("mfa_token", challenge.MFAToken)
+		fragment.Set("expires_in", strconv.FormatInt(challenge.ExpiresIn, 10))
+		c.Redirect(http.StatusFound, state.ReturnTo+"#"+fragment.Encode())
+		return
+	}
+
+	resp, err := s.issueTokens(c, user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+
+	if state.ReturnTo == "" {
+		c.JSON(http.StatusOK, resp)
+		return
+	}
+
+	// Tokens go in the fragment, which browsers never send to a server
+	fragment := url.Values{}
+	fragment.Set("token", resp.Token)
+	fragment.Set("refresh_token", resp.RefreshToken)
+	fragment.Set("expires_in", strconv.FormatInt(resp.ExpiresIn, 10))
+	c.Redirect(http.StatusFound, state.ReturnTo+"#"+fragment.Encode())
+}
+
+// oidcUser finds or creates the user behind an identity. A new identity is
+// linked to an existing account only when the provider vouches for the email
+// and the account's owner has verified it too; otherwise anyone could claim
+// an account by registering its address at a provider, or by signing up
+// locally with someone else's address before they arrive through SSO.
+func (s *AuthService) oidcUser(ctx context.Context, p *oidcProvider, subject string, claims map[string]interface{}) (models.User, error) {
+	var user models.User
+	email := normalizeEmail(claimString(claims, p.config.Claims.Email))
+	verified := p.config.TrustEmail || claims["email_verified"] == true
+	linked := false
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var identity models.UserIdentity
+		err := tx.Where("provider = ? AND subject = ?", p.config.Name, subject).First(&identity).Error
+		if err == nil {
+			if err := tx.Where("id = ?", identity.UserID).First(&user).Error; err != nil {
+				return err
+			}
+			return tx.Model(&identity).Updates(map[string]interface{}{"email": email, "last_login_at": time.Now()}).Error
+		}
+		if err != gorm.ErrRecordNotFound {
+			return err
+		}
+
+		if email == "" {
+			return errOIDCNoEmail
+		}
+
+		err = tx.Where("LOWER(email) = ?", email).First(&user).Error
+		switch {
+		case err == nil && !verified:
+			return errOIDCEmailConflict
+		case err == nil && user.EmailVerifiedAt == nil:
+			return errOIDCUnverified
+		case err == nil:
+			linked = true
+		case err == gorm.ErrRecordNotFound:
+			user, err = s.createOIDCUser(tx, p, claims, email, verified)
+			if err != nil {
+				return err
+			}
+		default:
+			return err
+		}
+
+		now := time.Now()
+		return tx.Create(&models.UserIdentity{
+			UserID:      user.ID,
+			Provider:    p.config.Name,
+			Subject:     subject,
+			Email:       email,
+			LastLoginAt: &now,
+		}).Error
+	})
+	if err != nil {
+		return user, err
+	}
+
+	if linked {
+		s.audit(models.AuditLog{
+			Event:    models.AuditOIDCLinked,
+			UserID:   &user.ID,
+			Username: user.Username,
+			Details:  "provider=" + p.config.Name,
+		})
+	}
+
+	// The provider is the source of truth for roles when it maps any
+	if role, ok := p.role(claims); ok && role != user.Role {
+		oldRole := user.Role
+		if err := s.db.Model(&user).Update("role", role).Error; err != nil {
+			return user, err
+		}
+		s.roleChanged(ctx, user, oldRole, models.AuditLog{Details: "provider=" + p.config.Name})
+	}
+
+	return user, nil
+}
+
+func (s *AuthService) createOIDCUser(tx *gorm.DB, p *oidcProvider, claims map[string]interface{}, email string, verified bool) (models.User, error) {
+	username := claimString(claims, p.config.Claims.Username)
+	if username == "" {
+		username = strings.SplitN(email, "@", 2)[0]
+	}
+	username, err := uniqueUsername(tx, username)
+	if err != nil {
+		return models.User{}, err
+	}
+
+	// Single sign-on accounts have no usable password until the user sets
+	// one through the reset flow
+	secret, err := newOpaqueToken()
+	if err != nil {
+		return models.User{}, err
+	}
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(secret), bcrypt.DefaultCost)
+	if err != nil {
+		return models.User{}, err
+	}
+
+	user := models.User{
+		Username: username,
+		Email:    email,
+		Password: string(hashedPassword),
+		Role:     rbac.RoleUser,
+		Status:   models.UserStatusActive,
+	}
+	if verified {
+		now := time.Now()
+		user.EmailVerifiedAt = &now
+	}
+
+	if err := tx.Create(&user).Error; err != nil {
+		return user, err
+	}
+	return user, nil
+}
+
+// role maps the role claim through the provider's table, picking the most
+// privileged match. Users matching nothing become plain users.
+func (p *oidcProvider) role(claims map[string]interface{}) (string, bool) {
+	if p.config.Claims.Role == "" || len(p.config.Roles) == 0 {
+		return "", false
+	}
+
+	matched := map[string]bool{}
+	for _, value := range claimStrings(claims, p.config.Claims.Role) {
+		if role, ok := p.config.Roles[value]; ok {
+			matched[role] = true
+		}
+	}
+
+	roles := rbac.Roles()
+	for i := len(roles) - 1; i >= 0; i-- {
+		if matched[roles[i]] {
+			return roles[i], true
+		}
+	}
+	return rbac.RoleUser, true
+}
+
+// uniqueUsername appends a number to base until it names no account,
+// deleted ones included.
+func uniqueUsername(tx *gorm.DB, base string) (string, error) {
+	username := base
+	for i := 2; i < 100; i++ {
+		var count int64
+		if err := tx.Unscoped().Model(&models.User{}).Where("username = ?", username).Count(&count).Error; err != nil {
+			return "", err
+		}
+		if count == 0 {
+			return username, nil
+		}
+		username = fmt.Sprintf("%s%d", base, i)
+	}
+	return "", fmt.Errorf("no free username for %q", base)
+}
+
+func (s *AuthService) validReturnTo(returnTo string) bool {
+	base := strings.TrimRight(s.config.AppBaseURL, "/")
+	return returnTo == base || strings.HasPrefix(returnTo, base+"/")
+}
+
+func (s *AuthService) findOIDCProvider(c *gin.Context) (*oidcProvider, bool) {
+	p, ok := s.oidc[c.Param("provider")]
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Unknown identity provider"})
+		return nil, false
+	}
+	return p, true
+}
+
+func claimString(claims map[string]interface{}, name string) string {
+	value, _ := claims[name].(string)
+	return value
+}
+
+// claimStrings reads a claim that may be a single string or a list, as group
+// and role claims vary between providers.
+func claimStrings(claims map[string]interface{}, name string) []string {
+	switch value := claims[name].(type) {
+	case string:
+		return []string{value}
+	case []interface{}:
+		values := make([]string, 0, len(value))
+		for _, v := range value {
+			if s, ok := v.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	}
+	return nil
+}
@@ -0,0 +1,349 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+
+	"news-aggregator/pkg/mailer"
+	"news-aggregator/pkg/models"
+	"news-aggregator/pkg/rbac"
+)
+
+func (s *AuthService) getMe(c *gin.Context) {
+	user, ok := s.currentUser(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"user":        user,
+		"permissions": rbac.Permissions(user.Role),
+	})
+}
+
+func (s *AuthService) updateMe(c *gin.Context) {
+	var req models.UpdateProfileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, ok := s.currentUser(c)
+	if !ok {
+		return
+	}
+
+	updates := map[string]interface{}{}
+
+	// Deleted accounts still hold their name and address until purged
+	if req.Username != nil && strings.TrimSpace(*req.Username) != user.Username {
+		username := strings.TrimSpace(*req.Username)
+		var count int64
+		s.db.Unscoped().Model(&models.User{}).Where("username = ? AND id <> ?", username, user.ID).Count(&count)
+		if count > 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "Username already taken"})
+			return
+		}
+		updates["username"] = username
+	}
+
+	var email string
+	if req.Email != nil {
+		email = normalizeEmail(*req.Email)
+	}
+	emailChanged := req.Email != nil && email != normalizeEmail(user.Email)
+	if emailChanged {
+		// The address is where password resets go, so a stolen access token
+		// alone must not be enough to move it
+		if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.CurrentPassword)); err != nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Current password is incorrect"})
+			return
+		}
+
+		var count int64
+		s.db.Unscoped().Model(&models.User{}).Where("LOWER(email) = ? AND id <> ?", email, user.ID).Count(&count)
+		if count > 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "Email already in use"})
+			return
+		}
+		updates["email"] = email
+		updates["email_verified_at"] = nil
+	}
+
+	if len(updates) == 0 {
+		c.JSON(http.StatusOK, user)
+		return
+	}
+
+	if err := s.db.Model(&user).Updates(updates).Error; err != nil {
+		log.Printf("Failed to update profile of user %d: %v", user.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update profile"})
+		return
+	}
+	oldEmail := user.Email
+	s.db.First(&user, user.ID)
+
+	// A new address has to be proven like the first one, and the old one
+	// hears about the change in case it was not the owner's doing
+	if emailChanged {
+		if err := s.sendVerification(user); err != nil {
+			log.Printf("Failed to send verification email to user %d: %v", user.ID, err)
+		}
+		go s.sendMail(mailer.Message{
+			To:      oldEmail,
+			Subject: "Your email address was changed",
+			Body: fmt.Sprintf("Hi %s,\n\nThe email address of your account was changed to %s. "+
+				"If you did not do this, reset your password and contact support.\n",
+				user.Username, user.Email),
+		})
+		s.audit(models.AuditLog{
+			Event:    models.AuditEmailChanged,
+			UserID:   &user.ID,
+			Username: user.Username,
+			IP:       c.ClientIP(),
+			Details:  fmt.Sprintf("%s -> %s", oldEmail, user.Email),
+		})
+	}
+	if _, ok := updates["username"]; ok {
+		s.syncAPIKeys(c.Request.Context(), user.ID)
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+// changePassword signs the user out everywhere and hands the caller a fresh
+// token pair, so only the device that changed the password stays logged in.
+func (s *AuthService) changePassword(c *gin.Context) {
+	var req models.ChangePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, ok := s.currentUser(c)
+	if !ok {
+		return
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.CurrentPassword)); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Current password is incorrect"})
+		return
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
+		return
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&user).Update("password", string(hashedPassword)).Error; err != nil {
+			return err
+		}
+		return revokeAllSessions(tx, user.ID)
+	})
+	if err != nil {
+		log.Printf("Failed to change password of user %d: %v", user.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to change password"})
+		return
+	}
+
+	if err := s.revocation.RevokeUser(c.Request.Context(), user.ID, s.accessTTL()); err != nil {
+		log.Printf("Failed to revoke tokens for user %d: %v", user.ID, err)
+	}
+
+	s.audit(models.AuditLog{Event: models.AuditPasswordChanged, UserID: &user.ID, Username: user.Username, IP: c.ClientIP()})
+
+	resp, err := s.issueTokens(c, user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// deleteMe soft-deletes the account and cuts off all its credentials at
+// once. The data itself goes in the next purge run.
+func (s *AuthService) deleteMe(c *gin.Context) {
+	var req models.DeleteAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, ok := s.currentUser(c)
+	if !ok {
+		return
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Password is incorrect"})
+		return
+	}
+	if user.TOTPEnabled {
+		if ok, err := s.checkSecondFactor(user, req.Code); err != nil || !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid code"})
+			return
+		}
+	}
+
+	if user.Role == rbac.RoleAdmin {
+		var admins int64
+		s.db.Model(&models.User{}).
+			Where("role = ? AND status = ? AND id <> ?", rbac.RoleAdmin, models.UserStatusActive, user.ID).
+			Count(&admins)
+		if admins == 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "Cannot delete the last admin account"})
+			return
+		}
+	}
+
+	var keyHashes []string
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.APIKey{}).Where("user_id = ? AND revoked_at IS NULL", user.ID).
+			Pluck("key_hash", &keyHashes).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.APIKey{}).Where("user_id = ? AND revoked_at IS NULL", user.ID).
+			Update("revoked_at", time.Now()).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&user).Error
+	})
+	if err != nil {
+		log.Printf("Failed to delete user %d: %v", user.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete account"})
+		return
+	}
+
+	s.revokeUserTokens(c.Request.Context(), user.ID, true)
+	if err := s.apiKeys.Delete(c.Request.Context(), keyHashes...); err != nil {
+		log.Printf("Failed to unpublish API keys of user %d: %v", user.ID, err)
+	}
+
+	s.audit(models.AuditLog{Event: models.AuditAccountDeleted, UserID: &user.ID, Username: user.Username, IP: c.ClientIP()})
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "Account deleted"})
+}
+
+func (s *AuthService) exportMe(c *gin.Context) {
+	user, ok := s.currentUser(c)
+	if !ok {
+		return
+	}
+
+	export := models.UserExport{
+		ExportedAt: time.Now(),
+		User:       user,
+		Identities: []models.UserIdentity{},
+		Sessions:   []models.Session{},
+		APIKeys:    []models.APIKey{},
+		Favorites:  []models.UserFavorite{},
+		AuditLogs:  []models.AuditLog{},
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", user.ID).Order("id").Find(&export.Identities).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", user.ID).Order("created_at").Find(&export.Sessions).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", user.ID).Order("id").Find(&export.APIKeys).Error; err != nil {
+			return err
+		}
+		if err := tx.Preload("News", func(db *gorm.DB) *gorm.DB {
+			return db.Omit("content", "content_html")
+		}).Where("user_id = ?", user.ID).Order("id").Find(&export.Favorites).Error; err != nil {
+			return err
+		}
+		return tx.Where("user_id = ? OR actor_id = ?", user.ID, user.ID).Order("id").Find(&export.AuditLogs).Error
+	})
+	if err != nil {
+		log.Printf("Failed to export data of user %d: %v", user.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export account data"})
+		return
+	}
+
+	c.Header("Content-Disposition", `attachment; filename="account-export.json"`)
+	c.JSON(http.StatusOK, export)
+}
+
+// purgeDeletedUsers removes deleted accounts and everything tied to them
+// once ACCOUNT_PURGE_DELAY_HOURS have passed.
+func (s *AuthService) purgeDeletedUsers() {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	for ; ; <-ticker.C {
+		cutoff := time.Now().Add(-time.Duration(s.config.AccountPurgeDelayHours) * time.Hour)
+
+		var userIDs []uint
+		if err := s.db.Unscoped().Model(&models.User{}).
+			Where("deleted_at IS NOT NULL AND deleted_at < ?", cutoff).
+			Pluck("id", &userIDs).Error; err != nil {
+			log.Printf("Failed to find deleted users: %v", err)
+			continue
+		}
+
+		for _, userID := range userIDs {
+			if err := s.purgeUser(context.Background(), userID); err != nil {
+				log.Printf("Failed to purge user %d: %v", userID, err)
+				continue
+			}
+			log.Printf("Purged deleted user %d", userID)
+		}
+	}
+}
+
+func (s *AuthService) purgeUser(ctx context.Context, userID uint) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Favorites are counted on the article, so take them back first
+		if err := tx.Model(&models.News{}).
+			Where("id IN (?)", tx.Model(&models.UserFavorite{}).Select("news_id").Where("user_id = ?", userID)).
+			UpdateColumn("favorite_count", gorm.Expr("GREATEST(favorite_count - 1, 0)")).Error; err != nil {
+			return err
+		}
+
+		for _, model := range []interface{}{
+			&models.UserFavorite{},
+			&models.RefreshToken{},
+			&models.Session{},
+			&models.UserToken{},
+			&models.RecoveryCode{},
+			&models.APIKey{},
+			&models.UserIdentity{},
+		} {
+			if err := tx.Where("user_id = ?", userID).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+
+		// Audit entries stay for security reviews but no longer name anyone
+		if err := tx.Model(&models.AuditLog{}).Where("user_id = ?", userID).
+			Updates(map[string]interface{}{"user_id": nil, "username": "", "ip": ""}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.AuditLog{}).Where("actor_id = ?", userID).
+			Update("actor_id", nil).Error; err != nil {
+			return err
+		}
+
+		return tx.Unscoped().Delete(&models.User{}, userID).Error
+	})
+}
+
+// normalizeEmail is the form addresses are stored and compared in; older
+// rows may still hold mixed case, so lookups compare LOWER(email).
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
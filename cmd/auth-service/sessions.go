@@ -0,0 +1,96 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"news-aggregator/pkg/middleware"
+	"news-aggregator/pkg/models"
+)
+
+func (s *AuthService) listSessions(c *gin.Context) {
+	userID, _ := middleware.UserID(c)
+
+	var sessions []models.Session
+	if err := s.db.Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, time.Now()).
+		Order("last_seen_at DESC").
+		Find(&sessions).Error; err != nil {
+		log.Printf("Failed to fetch sessions: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch sessions"})
+		return
+	}
+
+	current := currentSessionID(c)
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID == current
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": sessions})
+}
+
+// deleteSession signs one device out, including access tokens it already
+// holds. Deleting the current session works like logout.
+func (s *AuthService) deleteSession(c *gin.Context) {
+	userID, _ := middleware.UserID(c)
+
+	var session models.Session
+	if err := s.db.Where("id = ? AND user_id = ?", c.Param("id"), userID).First(&session).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+			return
+		}
+		log.Printf("Failed to fetch session: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch session"})
+		return
+	}
+
+	s.revokeFamily(session.ID)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Session revoked"})
+}
+
+// touchSession records the session on login and refreshes its device
+// details and expiry on every token rotation.
+func (s *AuthService) touchSession(db *gorm.DB, c *gin.Context, userID uint, sessionID string) error {
+	now := time.Now()
+	return db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"user_agent", "ip", "last_seen_at", "expires_at"}),
+	}).Create(&models.Session{
+		ID:         sessionID,
+		UserID:     userID,
+		UserAgent:  c.Request.UserAgent(),
+		IP:         c.ClientIP(),
+		LastSeenAt: now,
+		ExpiresAt:  now.Add(time.Duration(s.config.RefreshTokenTTLHours) * time.Hour),
+	}).Error
+}
+
+// revokeAllSessions ends every session of the user. Access tokens are left
+// to the per-user revocation watermark.
+func revokeAllSessions(tx *gorm.DB, userID uint) error {
+	now := time.Now()
+	if err := tx.Model(&models.RefreshToken{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", now).Error; err != nil {
+		return err
+	}
+	return tx.Model(&models.Session{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", now).Error
+}
+
+func currentSessionID(c *gin.Context) string {
+	claims, ok := c.Get("claims")
+	if !ok {
+		return ""
+	}
+	sid, _ := claims.(jwt.MapClaims)["sid"].(string)
+	return sid
+}
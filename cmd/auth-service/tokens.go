@@ -0,0 +1,244 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"news-aggregator/pkg/models"
+)
+
+var (
+	errRefreshInvalid = errors.New("invalid refresh token")
+	errRefreshReused  = errors.New("refresh token reuse detected")
+)
+
+func (s *AuthService) refresh(c *gin.Context) {
+	var req models.RefreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var user models.User
+	var refreshToken, sessionID string
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var current models.RefreshToken
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("token_hash = ?", hashToken(req.RefreshToken)).
+			First(&current).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return errRefreshInvalid
+			}
+			return err
+		}
+
+		if current.RevokedAt != nil || time.Now().After(current.ExpiresAt) {
+			return errRefreshInvalid
+		}
+
+		// A token that was already rotated is being replayed, so either the
+		// client or an attacker holds a stolen copy. Kill the whole family.
+		if current.UsedAt != nil {
+			return errRefreshReused
+		}
+
+		if err := tx.Where("id = ?", current.UserID).First(&user).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return errRefreshInvalid
+			}
+			return err
+		}
+		if user.Status == models.UserStatusSuspended {
+			return errRefreshInvalid
+		}
+
+		now := time.Now()
+		if err := tx.Model(&current).Update("used_at", now).Error; err != nil {
+			return err
+		}
+
+		sessionID = current.FamilyID
+		if err := s.touchSession(tx, c, user.ID, sessionID); err != nil {
+			return err
+		}
+
+		var err error
+		refreshToken, err = s.createRefreshToken(tx, c, user.ID, current.FamilyID)
+		return err
+	})
+	if err == errRefreshReused {
+		// Outside the transaction above so the revocation is not rolled back
+		s.revokeFamilyOf(req.RefreshToken)
+	}
+	if err == errRefreshInvalid || err == errRefreshReused {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid refresh token"})
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to rotate refresh token: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to refresh token"})
+		return
+	}
+
+	token, err := s.generateToken(user, sessionID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.AuthResponse{
+		Token:        token,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int64(s.accessTTL().Seconds()),
+		User:         user,
+	})
+}
+
+// issueTokens starts a new session, and with it a refresh token family, for
+// a fresh login and returns the response carrying both tokens.
+func (s *AuthService) issueTokens(c *gin.Context, user models.User) (*models.AuthResponse, error) {
+	sessionID := uuid.NewString()
+	if err := s.touchSession(s.db, c, user.ID, sessionID); err != nil {
+		return nil, err
+	}
+
+	token, err := s.generateToken(user, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken, err := s.createRefreshToken(s.db, c, user.ID, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.AuthResponse{
+		Token:        token,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int64(s.accessTTL().Seconds()),
+		User:         user,
+	}, nil
+}
+
+func (s *AuthService) generateToken(user models.User, sessionID string) (string, error) {
+	now := time.Now()
+	// iat carries milliseconds so a revocation watermark set just before
+	// this token was issued does not catch it
+	claims := jwt.MapClaims{
+		"jti":      uuid.NewString(),
+		"sid":      sessionID,
+		"userID":   user.ID,
+		"username": user.Username,
+		"role":     user.Role,
+		"iat":      float64(now.UnixMilli()) / 1000,
+		"exp":      now.Add(s.accessTTL()).Unix(),
+	}
+
+	return s.keys.sign(claims)
+}
+
+func (s *AuthService) accessTTL() time.Duration {
+	return time.Duration(s.config.JWTAccessTTLMinutes) * time.Minute
+}
+
+// createRefreshToken stores a new token in family and returns its opaque
+// value, which is never persisted.
+func (s *AuthService) createRefreshToken(db *gorm.DB, c *gin.Context, userID uint, familyID string) (string, error) {
+	token, err := newOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+
+	record := models.RefreshToken{
+		UserID:    userID,
+		FamilyID:  familyID,
+		TokenHash: hashToken(token),
+		ExpiresAt: time.Now().Add(time.Duration(s.config.RefreshTokenTTLHours) * time.Hour),
+		UserAgent: c.Request.UserAgent(),
+		IP:        c.ClientIP(),
+	}
+	if err := db.Create(&record).Error; err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+func (s *AuthService) revokeFamilyOf(token string) {
+	var record models.RefreshToken
+	if err := s.db.Where("token_hash = ?", hashToken(token)).First(&record).Error; err != nil {
+		return
+	}
+
+	log.Printf("Refresh token reuse for user %d, revoking family %s", record.UserID, record.FamilyID)
+	s.revokeFamily(record.FamilyID)
+}
+
+// revokeFamily ends the session the family belongs to: its refresh tokens
+// stop working and so do the access tokens already issued for it.
+func (s *AuthService) revokeFamily(familyID string) {
+	now := time.Now()
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.RefreshToken{}).
+			Where("family_id = ? AND revoked_at IS NULL", familyID).
+			Update("revoked_at", now).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.Session{}).
+			Where("id = ? AND revoked_at IS NULL", familyID).
+			Update("revoked_at", now).Error
+	})
+	if err != nil {
+		log.Printf("Failed to revoke refresh token family %s: %v", familyID, err)
+	}
+
+	if err := s.revocation.RevokeSession(context.Background(), familyID, s.accessTTL()); err != nil {
+		log.Printf("Failed to revoke session %s: %v", familyID, err)
+	}
+}
+
+// cleanupTokens drops expired tokens once a day. Used and revoked refresh
+// tokens are kept until expiry so replays are still recognised.
+func (s *AuthService) cleanupTokens() {
+	ticker := time.NewTicker(24 * time.Hour)
+	defer ticker.Stop()
+
+	for ; ; <-ticker.C {
+		if err := s.db.Where("expires_at < ?", time.Now()).Delete(&models.RefreshToken{}).Error; err != nil {
+			log.Printf("Failed to clean up refresh tokens: %v", err)
+		}
+		if err := s.db.Where("expires_at < ?", time.Now()).Delete(&models.UserToken{}).Error; err != nil {
+			log.Printf("Failed to clean up user tokens: %v", err)
+		}
+		if err := s.db.Where("expires_at < ?", time.Now()).Delete(&models.Session{}).Error; err != nil {
+			log.Printf("Failed to clean up sessions: %v", err)
+		}
+	}
+}
+
+// newOpaqueToken returns 256 random bits, URL-safe encoded.
+func newOpaqueToken() (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"news-aggregator/pkg/middleware"
+	"news-aggregator/pkg/models"
+)
+
+func (s *NewsAPIService) favoriteNews(c *gin.Context) {
+	userID, newsID, ok := s.favoriteParams(c)
+	if !ok {
+		return
+	}
+
+	var added bool
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var news models.News
+		if err := tx.Select("id").Where("id = ?", newsID).First(&news).Error; err != nil {
+			return err
+		}
+
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).
+			Create(&models.UserFavorite{UserID: userID, NewsID: newsID})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return nil // Already a favorite
+		}
+
+		added = true
+		return tx.Model(&models.News{}).Where("id = ?", newsID).
+			UpdateColumn("favorite_count", gorm.Expr("favorite_count + 1")).Error
+	})
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "News not found"})
+			return
+		}
+		s.logger.Error("Failed to favorite news", zap.Uint("newsID", newsID), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to favorite news"})
+		return
+	}
+
+	// Lists carry the count too, so the new one shows up immediately
+	s.invalidateNewsCache(newsID)
+	s.invalidateNewsLists()
+
+	status := http.StatusOK
+	if added {
+		status = http.StatusCreated
+	}
+	c.JSON(status, s.loadFavoriteStatus(userID, newsID))
+}
+
+func (s *NewsAPIService) unfavoriteNews(c *gin.Context) {
+	userID, newsID, ok := s.favoriteParams(c)
+	if !ok {
+		return
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("user_id = ? AND news_id = ?", userID, newsID).Delete(&models.UserFavorite{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return nil
+		}
+
+		return tx.Model(&models.News{}).Where("id = ? AND favorite_count > 0", newsID).
+			UpdateColumn("favorite_count", gorm.Expr("favorite_count - 1")).Error
+	})
+	if err != nil {
+		s.logger.Error("Failed to unfavorite news", zap.Uint("newsID", newsID), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unfavorite news"})
+		return
+	}
+
+	// Lists carry the count too, so the new one shows up immediately
+	s.invalidateNewsCache(newsID)
+	s.invalidateNewsLists()
+
+	c.JSON(http.StatusOK, s.loadFavoriteStatus(userID, newsID))
+}
+
+func (s *NewsAPIService) favoriteStatus(c *gin.Context) {
+	userID, newsID, ok := s.favoriteParams(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, s.loadFavoriteStatus(userID, newsID))
+}
+
+func (s *NewsAPIService) listFavorites(c *gin.Context) {
+	userID, ok := middleware.UserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+		return
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	offset := (page - 1) * limit
+
+	query := s.db.Model(&models.News{}).
+		Joins("JOIN user_favorites ON user_favorites.news_id = news.id").
+		Where("user_favorites.user_id = ?", userID)
+
+	var total int64
+	query.Count(&total)
+
+	var news []models.News
+	if err := query.Preload("Source").
+		Omit("content", "content_html").
+		Order("user_favorites.created_at DESC").
+		Limit(limit).
+		Offset(offset).
+		Find(&news).Error; err != nil {
+		s.logger.Error("Failed to fetch favorites", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch favorites"})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.NewsResponse{
+		Data:  news,
+		Total: total,
+		Page:  page,
+		Limit: limit,
+	})
+}
+
+// favoriteParams reads the authenticated user and the :id news parameter,
+// writing the error response itself when either is missing.
+func (s *NewsAPIService) favoriteParams(c *gin.Context) (uint, uint, bool) {
+	userID, ok := middleware.UserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+		return 0, 0, false
+	}
+
+	newsID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil || newsID == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid news ID"})
+		return 0, 0, false
+	}
+
+	return userID, uint(newsID), true
+}
+
+func (s *NewsAPIService) loadFavoriteStatus(userID, newsID uint) models.FavoriteStatus {
+	status := models.FavoriteStatus{NewsID: newsID}
+
+	var count int64
+	s.db.Model(&models.UserFavorite{}).Where("user_id = ? AND news_id = ?", userID, newsID).Count(&count)
+	status.Favorited = count > 0
+
+	s.db.Model(&models.News{}).Select("favorite_count").Where("id = ?", newsID).Scan(&status.FavoriteCount)
+
+	return status
+}
+
+// invalidateNewsCache drops the cached single-article response.
+func (s *NewsAPIService) invalidateNewsCache(newsID uint) {
+	s.redis.Del(context.Background(), newsCacheKey(newsID))
+}
+
+func newsCacheKey(newsID uint) string {
+	return fmt.Sprintf("news:id_%d", newsID)
+}
+
+const newsListVersionKey = "news:list_version"
+
+// newsListCacheKey includes a version number, so bumping it retires every
+// cached page at once without scanning for keys. The old entries simply
+// expire.
+func (s *NewsAPIService) newsListCacheKey(page, limit int, source, search string) string {
+	version, _ := s.redis.Get(context.Background(), newsListVersionKey).Int64()
+	return fmt.Sprintf("news:v%d:page_%d:limit_%d:source_%s:search_%s", version, page, limit, source, search)
+}
+
+func (s *NewsAPIService) invalidateNewsLists() {
+	if err := s.redis.Incr(context.Background(), newsListVersionKey).Err(); err != nil {
+		s.logger.Warn("Failed to invalidate cached news lists", zap.Error(err))
+	}
+}
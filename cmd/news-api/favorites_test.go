@@ -0,0 +1,30 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+func TestInvalidateNewsLists(t *testing.T) {
+	mr := miniredis.RunT(t)
+	s := &NewsAPIService{
+		redis:  redis.NewClient(&redis.Options{Addr: mr.Addr()}),
+		logger: zap.NewNop(),
+	}
+
+	before := s.newsListCacheKey(1, 20, "vnexpress", "")
+	if again := s.newsListCacheKey(1, 20, "vnexpress", ""); again != before {
+		t.Fatalf("key changed without invalidation: %q != %q", again, before)
+	}
+	if other := s.newsListCacheKey(2, 20, "vnexpress", ""); other == before {
+		t.Errorf("pages share the key %q", other)
+	}
+
+	s.invalidateNewsLists()
+	if after := s.newsListCacheKey(1, 20, "vnexpress", ""); after == before {
+		t.Errorf("key %q survived invalidation", after)
+	}
+}
@@ -3,7 +3,6 @@ package main
 import (
 	"context"
 	"encoding/json"
-	"fmt"
 	"log"
 	"net/http"
 	"strconv"
@@ -16,10 +15,17 @@ import (
 	"gorm.io/gorm"
 
 	"news-aggregator/pkg/config"
+	"news-aggregator/pkg/events"
+	"news-aggregator/pkg/jwks"
+	"news-aggregator/pkg/metrics"
 	"news-aggregator/pkg/middleware"
 	"news-aggregator/pkg/models"
+	"news-aggregator/pkg/ratelimit"
+	"news-aggregator/pkg/rbac"
 )
 
+const serviceName = "news-api"
+
 type NewsAPIService struct {
 	db     *gorm.DB
 	redis  *redis.Client
@@ -40,11 +46,24 @@ func main() {
 		logger.Fatal("Failed to connect to database", zap.Error(err))
 	}
 
+	// Auto migrate
+	db.AutoMigrate(&models.Source{}, &models.News{}, &models.UserFavorite{}, &models.OutboxEvent{})
+	if err := models.MigrateSearch(db); err != nil {
+		logger.Fatal("Failed to migrate search index", zap.Error(err))
+	}
+
 	// Redis connection
 	rdb := redis.NewClient(&redis.Options{
 		Addr: cfg.RedisURL,
 	})
 
+	if err := metrics.RegisterDB(db, "postgres"); err != nil {
+		logger.Error("Failed to register database metrics", zap.Error(err))
+	}
+	if err := metrics.RegisterRedis(rdb); err != nil {
+		logger.Error("Failed to register Redis metrics", zap.Error(err))
+	}
+
 	service := &NewsAPIService{
 		db:     db,
 		redis:  rdb,
@@ -53,13 +72,25 @@ func main() {
 	}
 
 	router := gin.Default()
+	// Client IPs key the rate limit, so only the gateway may vouch for them
+	if err := router.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		logger.Fatal("Invalid TRUSTED_PROXIES", zap.Error(err))
+	}
 
 	// Setup middleware
 	router.Use(middleware.Logger())
+	router.Use(metrics.Middleware())
 	router.Use(middleware.CORS())
 
-	auth := middleware.NewAuthMiddleware(cfg.JWTSecret, rdb)
-	router.Use(auth.RateLimit(cfg.RateLimitReqs, time.Duration(cfg.RateLimitWindow)*time.Second))
+	keys := jwks.NewClient(cfg.JWKSURL, time.Duration(cfg.JWKSCacheMinutes)*time.Minute)
+	auth := middleware.NewAuthMiddleware(keys.Keyfunc, rdb)
+	tiers, err := ratelimit.ParseTiers(cfg.RateLimitReqs, time.Duration(cfg.RateLimitWindow)*time.Second, cfg.RateLimitTiers)
+	if err != nil {
+		logger.Fatal("Invalid rate limit tiers", zap.Error(err))
+	}
+	// Requests through the gateway were counted there already; a bucket of
+	// its own keeps them from being counted twice
+	router.Use(auth.RateLimit(middleware.RateLimitOptions{Scope: "news-api", Tiers: tiers, FailOpen: cfg.RateLimitFailOpen}))
 
 	service.setupRoutes(router, auth)
 
@@ -72,19 +103,44 @@ func (s *NewsAPIService) setupRoutes(router *gin.Engine, auth *middleware.AuthMi
 	{
 		// Public endpoints
 		api.GET("/news", s.getNews)
+		api.GET("/news/search", s.searchNews)
 		api.GET("/news/:id", s.getNewsById)
 		api.GET("/news/source/:source", s.getNewsBySource)
 
 		// Protected endpoints
 		protected := api.Group("")
-		protected.Use(auth.JWTAuth())
+		protected.Use(auth.JWTAuth(), middleware.RequireScope(rbac.ScopeFavorites))
 		{
 			protected.POST("/news/favorite/:id", s.favoriteNews)
+			protected.DELETE("/news/favorite/:id", s.unfavoriteNews)
+			protected.GET("/news/favorite/:id", s.favoriteStatus)
+			protected.GET("/me/favorites", s.listFavorites)
+		}
+
+		// Moderation endpoints
+		moderation := api.Group("")
+		moderation.Use(auth.JWTAuth(), middleware.RequirePermission(rbac.PermNewsModerate))
+		{
+			moderation.DELETE("/news/:id", s.deleteNews)
+		}
+
+		// Admin endpoints
+		admin := api.Group("/admin")
+		admin.Use(auth.JWTAuth(), middleware.RequirePermission(rbac.PermSourcesManage))
+		{
+			admin.GET("/sources", s.listSources)
+			admin.POST("/sources", s.createSource)
+			admin.GET("/sources/:id", s.getSource)
+			admin.PUT("/sources/:id", s.updateSource)
+			admin.POST("/sources/:id/enable", s.enableSource)
+			admin.POST("/sources/:id/disable", s.disableSource)
+			admin.DELETE("/sources/:id", s.deleteSource)
 		}
 	}
 
 	// Health check
 	router.GET("/health", s.healthCheck)
+	router.GET("/metrics", gin.WrapH(metrics.Handler()))
 }
 
 func (s *NewsAPIService) getNews(c *gin.Context) {
@@ -104,23 +160,31 @@ func (s *NewsAPIService) getNews(c *gin.Context) {
 	offset := (page - 1) * limit
 
 	// Check cache first
-	cacheKey := fmt.Sprintf("news:page_%d:limit_%d:source_%s:search_%s", page, limit, source, search)
+	cacheKey := s.newsListCacheKey(page, limit, source, search)
 	cached, err := s.redis.Get(context.Background(), cacheKey).Result()
 	if err == nil && cached != "" {
+		metrics.CacheLookup("news_list", true)
 		c.Header("X-Cache", "HIT")
 		c.Data(http.StatusOK, "application/json", []byte(cached))
 		return
 	}
+	metrics.CacheLookup("news_list", false)
 
 	// Build query
 	query := s.db.Model(&models.News{})
 
 	if source != "" {
-		query = query.Where("source ILIKE ?", "%"+source+"%")
+		query = query.Where("source_id IN (?)", s.sourceIDsMatching(source))
 	}
 
 	if search != "" {
-		query = query.Where("title ILIKE ? OR description ILIKE ?", "%"+search+"%", "%"+search+"%")
+		tsquery, args, err := s.searchQuery(search, "")
+		if err != nil {
+			s.logger.Error("Failed to build search query", zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch news"})
+			return
+		}
+		query = query.Where("search_vector @@ "+tsquery, args...)
 	}
 
 	// Get total count
@@ -129,7 +193,9 @@ func (s *NewsAPIService) getNews(c *gin.Context) {
 
 	// Get news with pagination
 	var news []models.News
-	if err := query.Order("published_at DESC").
+	if err := query.Preload("Source").
+		Omit("content", "content_html").
+		Order("published_at DESC").
 		Limit(limit).
 		Offset(offset).
 		Find(&news).Error; err != nil {
@@ -154,19 +220,27 @@ func (s *NewsAPIService) getNews(c *gin.Context) {
 }
 
 func (s *NewsAPIService) getNewsById(c *gin.Context) {
-	id := c.Param("id")
+	// Parsed so that /news/007 and /news/7 share the cache entry that
+	// invalidateNewsCache drops
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil || id == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "News not found"})
+		return
+	}
 
 	// Check cache
-	cacheKey := fmt.Sprintf("news:id_%s", id)
+	cacheKey := newsCacheKey(uint(id))
 	cached, err := s.redis.Get(context.Background(), cacheKey).Result()
 	if err == nil && cached != "" {
+		metrics.CacheLookup("news_item", true)
 		c.Header("X-Cache", "HIT")
 		c.Data(http.StatusOK, "application/json", []byte(cached))
 		return
 	}
+	metrics.CacheLookup("news_item", false)
 
 	var news models.News
-	if err := s.db.Where("id = ?", id).First(&news).Error; err != nil {
+	if err := s.db.Preload("Source").Where("id = ?", id).First(&news).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			c.JSON(http.StatusNotFound, gin.H{"error": "News not found"})
 			return
@@ -202,10 +276,12 @@ func (s *NewsAPIService) getNewsBySource(c *gin.Context) {
 	var total int64
 
 	// Get total count
-	s.db.Model(&models.News{}).Where("source ILIKE ?", "%"+source+"%").Count(&total)
+	s.db.Model(&models.News{}).Where("source_id IN (?)", s.sourceIDsMatching(source)).Count(&total)
 
 	// Get news
-	if err := s.db.Where("source ILIKE ?", "%"+source+"%").
+	if err := s.db.Preload("Source").
+		Omit("content", "content_html").
+		Where("source_id IN (?)", s.sourceIDsMatching(source)).
 		Order("published_at DESC").
 		Limit(limit).
 		Offset(offset).
@@ -225,17 +301,49 @@ func (s *NewsAPIService) getNewsBySource(c *gin.Context) {
 	c.JSON(http.StatusOK, response)
 }
 
-func (s *NewsAPIService) favoriteNews(c *gin.Context) {
-	newsID := c.Param("id")
-	userID := c.GetString("userID")
+// deleteNews removes an article and announces it with a news.deleted event,
+// written through the outbox the scraper relays.
+func (s *NewsAPIService) deleteNews(c *gin.Context) {
+	var news models.News
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("id = ?", c.Param("id")).First(&news).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&news).Error; err != nil {
+			return err
+		}
+
+		env, err := events.New(events.TypeNewsDeleted, serviceName, events.NewsDeletedPayload{ID: news.ID, URL: news.URL})
+		if err != nil {
+			return err
+		}
+		event, err := events.Outbox(env, events.NewsKey(news.ID))
+		if err != nil {
+			return err
+		}
+		return tx.Create(event).Error
+	})
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "News not found"})
+			return
+		}
+		s.logger.Error("Failed to delete news", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete news"})
+		return
+	}
+
+	// Cached lists would otherwise keep showing it until they expire
+	s.invalidateNewsCache(news.ID)
+	s.invalidateNewsLists()
 
-	// Implementation for favorite functionality
-	// This would typically involve a user_favorites table
-	s.logger.Info("User favorited news",
-		zap.String("userID", userID),
-		zap.String("newsID", newsID))
+	c.JSON(http.StatusOK, gin.H{"message": "News deleted successfully"})
+}
 
-	c.JSON(http.StatusOK, gin.H{"message": "News favorited successfully"})
+// sourceIDsMatching is a subquery selecting the sources whose display name
+// contains name.
+func (s *NewsAPIService) sourceIDsMatching(name string) *gorm.DB {
+	return s.db.Model(&models.Source{}).Select("id").Where("name ILIKE ?", "%"+name+"%")
 }
 
 func (s *NewsAPIService) healthCheck(c *gin.Context) {
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+
+	"news-aggregator/pkg/models"
+)
+
+// Headlines are cut from plain text: the extracted article when there is
+// one, else the feed description with its markup stripped. ts_headline
+// marks matches with control characters that cannot occur in feed XML, so
+// the excerpt can be escaped before they are turned into <mark> tags.
+const (
+	headlineSource  = `coalesce(nullif(news.content, ''), regexp_replace(coalesce(nullif(news.description, ''), news.title), '<[^>]*>', ' ', 'g'))`
+	headlineOptions = "StartSel=\x02, StopSel=\x03, MaxWords=35, MinWords=15, MaxFragments=2"
+)
+
+var headlineMarks = strings.NewReplacer("\x02", "<mark>", "\x03", "</mark>")
+
+// safeHeadline escapes a ts_headline excerpt and marks its matches. Feed
+// text arrives entity-encoded, so it is decoded first to avoid escaping
+// twice.
+func safeHeadline(raw string) string {
+	return headlineMarks.Replace(html.EscapeString(html.UnescapeString(raw)))
+}
+
+// searchNews ranks articles against a web-style query: quoted phrases, OR and
+// a leading - for exclusion are understood, anything else is ignored rather
+// than rejected.
+func (s *NewsAPIService) searchNews(c *gin.Context) {
+	q := strings.TrimSpace(c.Query("q"))
+	if q == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Query parameter q is required"})
+		return
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	source := c.Query("source")
+	lang := c.Query("lang")
+
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	offset := (page - 1) * limit
+
+	if lang != "" && !s.validSearchConfig(lang) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown lang"})
+		return
+	}
+
+	tsquery, args, err := s.searchQuery(q, lang)
+	if err != nil {
+		s.logger.Error("Failed to build search query", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to search news"})
+		return
+	}
+
+	query := s.db.Model(&models.News{}).Where("search_vector @@ "+tsquery, args...)
+	if lang != "" {
+		query = query.Where("search_config = ?::regconfig", lang)
+	}
+	if source != "" {
+		query = query.Where("source_id IN (?)", s.sourceIDsMatching(source))
+	}
+
+	var total int64
+	query.Count(&total)
+
+	// Rank first and highlight only the page that is returned, since
+	// ts_headline has to re-parse each document.
+	var hits []struct {
+		ID       uint
+		Rank     float64
+		Headline string
+	}
+	if err := s.db.Table("(?) AS hits", query.
+		Select("id, ts_rank(search_vector, "+tsquery+") AS rank", args...).
+		Order("rank DESC, published_at DESC").
+		Limit(limit).
+		Offset(offset)).
+		Joins("JOIN news ON news.id = hits.id").
+		Select("hits.id, hits.rank, ts_headline(news.search_config, "+headlineSource+", websearch_to_tsquery(news.search_config, ?), ?) AS headline", q, headlineOptions).
+		Order("hits.rank DESC, news.published_at DESC").
+		Scan(&hits).Error; err != nil {
+		s.logger.Error("Failed to search news", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to search news"})
+		return
+	}
+
+	results := make([]models.NewsSearchResult, 0, len(hits))
+	if len(hits) > 0 {
+		ids := make([]uint, len(hits))
+		for i, hit := range hits {
+			ids[i] = hit.ID
+		}
+
+		var news []models.News
+		if err := s.db.Preload("Source").
+			Omit("content", "content_html").
+			Where("id IN ?", ids).
+			Find(&news).Error; err != nil {
+			s.logger.Error("Failed to fetch news", zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to search news"})
+			return
+		}
+
+		byID := make(map[uint]models.News, len(news))
+		for _, n := range news {
+			byID[n.ID] = n
+		}
+		for _, hit := range hits {
+			if n, ok := byID[hit.ID]; ok {
+				results = append(results, models.NewsSearchResult{News: n, Rank: hit.Rank, Headline: safeHeadline(hit.Headline)})
+			}
+		}
+	}
+
+	c.JSON(http.StatusOK, models.NewsSearchResponse{
+		Data:  results,
+		Query: q,
+		Total: total,
+		Page:  page,
+		Limit: limit,
+	})
+}
+
+// searchQuery builds a tsquery expression for q. Each article is indexed with
+// its source's configuration, so the query is parsed once per configuration in
+// use and the results OR'ed together. That keeps the expression constant, so
+// the GIN index applies, while still stemming every language.
+func (s *NewsAPIService) searchQuery(q, lang string) (string, []interface{}, error) {
+	configs := []string{lang}
+	if lang == "" {
+		// Articles from before a source was configured keep the default
+		configs = []string{"simple"}
+
+		var used []string
+		if err := s.db.Unscoped().Model(&models.Source{}).
+			Where("search_config <> ?", "simple").
+			Distinct().
+			Pluck("search_config", &used).Error; err != nil {
+			return "", nil, err
+		}
+		configs = append(configs, used...)
+	}
+
+	parts := make([]string, len(configs))
+	args := make([]interface{}, 0, 2*len(configs))
+	for i, config := range configs {
+		parts[i] = "websearch_to_tsquery(?::regconfig, ?)"
+		args = append(args, config, q)
+	}
+
+	return fmt.Sprintf("(%s)", strings.Join(parts, " || ")), args, nil
+}
+
+// validSearchConfig reports whether name is a text search configuration the
+// database knows, e.g. "english" or "simple".
+func (s *NewsAPIService) validSearchConfig(name string) bool {
+	var count int64
+	s.db.Table("pg_ts_config").Where("cfgname = ?", name).Count(&count)
+	return count > 0
+}
+
+// applySearchConfig re-indexes a source's articles after its configuration
+// changed. The generated search_vector column follows automatically.
+func (s *NewsAPIService) applySearchConfig(tx *gorm.DB, sourceID uint, config string) error {
+	return tx.Model(&models.News{}).
+		Where("source_id = ? AND search_config <> ?::regconfig", sourceID, config).
+		UpdateColumn("search_config", gorm.Expr("?::regconfig", config)).Error
+}
@@ -0,0 +1,22 @@
+package main
+
+import "testing"
+
+func TestSafeHeadline(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want string
+	}{
+		{"giá \x02vàng\x03 tăng", "giá <mark>vàng</mark> tăng"},
+		{"<img src=x onerror=alert(1)> \x02hit\x03", "&lt;img src=x onerror=alert(1)&gt; <mark>hit</mark>"},
+		{"Tom &amp; \x02Jerry\x03", "Tom &amp; <mark>Jerry</mark>"},
+		{"&lt;script&gt;", "&lt;script&gt;"},
+		{`say "hi"`, "say &#34;hi&#34;"},
+	}
+
+	for _, tt := range tests {
+		if got := safeHeadline(tt.raw); got != tt.want {
+			t.Errorf("safeHeadline(%q) = %q, want %q", tt.raw, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+
+	"news-aggregator/pkg/models"
+)
+
+func (s *NewsAPIService) listSources(c *gin.Context) {
+	query := s.db.Model(&models.Source{})
+
+	switch c.Query("enabled") {
+	case "true":
+		query = query.Where("enabled = ?", true)
+	case "false":
+		query = query.Where("enabled = ?", false)
+	}
+
+	var sources []models.Source
+	if err := query.Order("id").Find(&sources).Error; err != nil {
+		s.logger.Error("Failed to fetch sources", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch sources"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": sources, "total": len(sources)})
+}
+
+func (s *NewsAPIService) getSource(c *gin.Context) {
+	source, ok := s.findSource(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, source)
+}
+
+func (s *NewsAPIService) createSource(c *gin.Context) {
+	var req models.CreateSourceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.SearchConfig == "" {
+		req.SearchConfig = "simple"
+	}
+	if !s.validSearchConfig(req.SearchConfig) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown search_config"})
+		return
+	}
+
+	source := models.Source{
+		URL:            req.URL,
+		Name:           req.Name,
+		Category:       req.Category,
+		Language:       req.Language,
+		SearchConfig:   req.SearchConfig,
+		Enabled:        true,
+		PollInterval:   req.PollInterval,
+		ExtractContent: req.ExtractContent,
+	}
+	if req.Enabled != nil {
+		source.Enabled = *req.Enabled
+	}
+
+	// A deleted source with the same URL still holds the unique index, so
+	// bring it back instead of inserting a duplicate.
+	var existing models.Source
+	err := s.db.Unscoped().Where("url = ?", req.URL).First(&existing).Error
+	switch {
+	case err == nil && existing.DeletedAt.Valid:
+		source.ID = existing.ID
+		source.CreatedAt = existing.CreatedAt
+		if source.PollInterval == 0 {
+			source.PollInterval = existing.PollInterval
+		}
+		err = s.db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Unscoped().Save(&source).Error; err != nil {
+				return err
+			}
+			return s.applySearchConfig(tx, source.ID, source.SearchConfig)
+		})
+	case err == nil:
+		c.JSON(http.StatusConflict, gin.H{"error": "Source already exists"})
+		return
+	case err == gorm.ErrRecordNotFound:
+		err = s.db.Create(&source).Error
+	}
+	if err != nil {
+		s.logger.Error("Failed to create source", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create source"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, source)
+}
+
+func (s *NewsAPIService) updateSource(c *gin.Context) {
+	source, ok := s.findSource(c)
+	if !ok {
+		return
+	}
+
+	var req models.UpdateSourceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	updates := map[string]interface{}{}
+	if req.URL != nil {
+		// Deleted sources still hold their URL in the unique index
+		var count int64
+		if err := s.db.Unscoped().Model(&models.Source{}).Where("url = ? AND id <> ?", *req.URL, source.ID).Count(&count).Error; err != nil {
+			s.logger.Error("Failed to check source URL", zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update source"})
+			return
+		}
+		if count > 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "Source already exists"})
+			return
+		}
+
+		// Validators belong to the old URL
+		updates["url"] = *req.URL
+		updates["e_tag"] = ""
+		updates["last_modified"] = ""
+	}
+	if req.Name != nil {
+		updates["name"] = *req.Name
+	}
+	if req.Category != nil {
+		updates["category"] = *req.Category
+	}
+	if req.Language != nil {
+		updates["language"] = *req.Language
+	}
+	if req.SearchConfig != nil {
+		if !s.validSearchConfig(*req.SearchConfig) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown search_config"})
+			return
+		}
+		updates["search_config"] = *req.SearchConfig
+	}
+	if req.Enabled != nil {
+		updates["enabled"] = *req.Enabled
+	}
+	if req.PollInterval != nil {
+		// Let the scheduler re-learn the interval from the new baseline
+		updates["poll_interval"] = *req.PollInterval
+		updates["fetch_interval"] = 0
+	}
+	if req.ExtractContent != nil {
+		updates["extract_content"] = *req.ExtractContent
+	}
+	if req.URL != nil || (req.Enabled != nil && *req.Enabled) {
+		updates["next_fetch_at"] = nil
+	}
+
+	if len(updates) > 0 {
+		if err := s.db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Model(&source).Updates(updates).Error; err != nil {
+				return err
+			}
+			if req.SearchConfig == nil {
+				return nil
+			}
+			// Rewriting search_config regenerates the articles' vectors
+			return s.applySearchConfig(tx, source.ID, *req.SearchConfig)
+		}); err != nil {
+			s.logger.Error("Failed to update source", zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update source"})
+			return
+		}
+		s.db.First(&source, source.ID)
+	}
+
+	c.JSON(http.StatusOK, source)
+}
+
+func (s *NewsAPIService) enableSource(c *gin.Context) {
+	s.setSourceEnabled(c, true)
+}
+
+func (s *NewsAPIService) disableSource(c *gin.Context) {
+	s.setSourceEnabled(c, false)
+}
+
+func (s *NewsAPIService) setSourceEnabled(c *gin.Context, enabled bool) {
+	source, ok := s.findSource(c)
+	if !ok {
+		return
+	}
+
+	updates := map[string]interface{}{"enabled": enabled}
+	if enabled {
+		// Fetch on the scheduler's next pass
+		updates["next_fetch_at"] = nil
+	}
+
+	if err := s.db.Model(&source).Updates(updates).Error; err != nil {
+		s.logger.Error("Failed to update source", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update source"})
+		return
+	}
+	s.db.First(&source, source.ID)
+
+	c.JSON(http.StatusOK, source)
+}
+
+func (s *NewsAPIService) deleteSource(c *gin.Context) {
+	source, ok := s.findSource(c)
+	if !ok {
+		return
+	}
+
+	// Soft delete keeps the articles' source_id pointing at a real row
+	if err := s.db.Delete(&source).Error; err != nil {
+		s.logger.Error("Failed to delete source", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete source"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Source deleted successfully"})
+}
+
+// findSource loads the source named by the :id path parameter, writing the
+// error response itself when it cannot.
+func (s *NewsAPIService) findSource(c *gin.Context) (models.Source, bool) {
+	var source models.Source
+	if err := s.db.Where("id = ?", c.Param("id")).First(&source).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Source not found"})
+			return source, false
+		}
+		s.logger.Error("Failed to fetch source", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch source"})
+		return source, false
+	}
+
+	return source, true
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+
+	"go.uber.org/zap"
+	"golang.org/x/net/html/charset"
+
+	"news-aggregator/pkg/config"
+	"news-aggregator/pkg/extract"
+	"news-aggregator/pkg/models"
+)
+
+const (
+	extractQueueSize    = 256
+	maxArticleRedirects = 5
+)
+
+var errNonPublicAddress = errors.New("refusing to connect to a non-public address")
+
+// nonPublicPrefixes are reserved ranges netip has no predicate for.
+var nonPublicPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("192.0.2.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("198.51.100.0/24"),
+	netip.MustParsePrefix("203.0.113.0/24"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("64:ff9b::/96"),
+	netip.MustParsePrefix("64:ff9b:1::/48"),
+	netip.MustParsePrefix("2001:db8::/32"),
+}
+
+type extractJob struct {
+	newsID uint
+	url    string
+	author string
+}
+
+// startExtractors runs the configured number of extraction workers. Feed
+// processing only queues jobs, so a slow article site never holds up polling
+// beyond the queue filling up.
+func (s *NewsScraperService) startExtractors() {
+	if s.config.ScraperExtractConcurrency < 1 {
+		return
+	}
+
+	s.extractJobs = make(chan extractJob, extractQueueSize)
+	for i := 0; i < s.config.ScraperExtractConcurrency; i++ {
+		go func() {
+			for job := range s.extractJobs {
+				s.extractArticle(job)
+			}
+		}()
+	}
+}
+
+func (s *NewsScraperService) queueExtraction(source *models.Source, news models.News) {
+	if !source.ExtractContent || s.extractJobs == nil {
+		return
+	}
+
+	select {
+	case s.extractJobs <- extractJob{newsID: news.ID, url: news.URL, author: news.Author}:
+	case <-s.shutdown:
+	}
+}
+
+func (s *NewsScraperService) extractArticle(job extractJob) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(s.config.ScraperHTTPTimeout)*time.Second)
+	defer cancel()
+
+	article, err := s.fetchArticle(ctx, job.url)
+	if err != nil {
+		s.logger.Warn("Failed to extract article", zap.String("url", job.url), zap.Error(err))
+		return
+	}
+
+	updates := map[string]interface{}{
+		"content":      article.Text,
+		"content_html": article.HTML,
+		"lead_image":   article.LeadImage,
+		"word_count":   article.WordCount,
+		"reading_time": article.ReadingTime,
+		"extracted_at": time.Now(),
+	}
+	// The feed's own author wins over whatever the page advertises
+	if job.author == "" && article.Author != "" {
+		updates["author"] = article.Author
+	}
+
+	if err := s.db.Model(&models.News{}).Where("id = ?", job.newsID).Updates(updates).Error; err != nil {
+		s.logger.Error("Failed to save extracted article", zap.Uint("newsID", job.newsID), zap.Error(err))
+	}
+}
+
+// newArticleClient builds the client for article pages. Their URLs come
+// from whoever publishes a feed and the page ends up readable through the
+// news API, so it must not reach loopback, the compose network or cloud
+// metadata endpoints. The address is checked after DNS resolution on every
+// connection, redirects included, and no proxy is used since it would dial
+// on our behalf.
+func newArticleClient(cfg *config.Config) *http.Client {
+	transport := &http.Transport{
+		DialContext: (&net.Dialer{
+			Timeout:   10 * time.Second,
+			KeepAlive: 30 * time.Second,
+			Control:   publicAddressOnly,
+		}).DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          100,
+		MaxIdleConnsPerHost:   4,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ResponseHeaderTimeout: 20 * time.Second,
+		DisableCompression:    true,
+	}
+
+	return &http.Client{
+		Transport: transport,
+		Timeout:   time.Duration(cfg.ScraperHTTPTimeout) * time.Second,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxArticleRedirects {
+				return fmt.Errorf("stopped after %d redirects", maxArticleRedirects)
+			}
+			return checkArticleURL(req.URL)
+		},
+	}
+}
+
+func checkArticleURL(u *url.URL) error {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("unsupported URL scheme %q", u.Scheme)
+	}
+	return nil
+}
+
+// publicAddressOnly is a net.Dialer Control hook, called with the resolved
+// address of each connection.
+func publicAddressOnly(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip, err := netip.ParseAddr(host)
+	if err != nil {
+		return err
+	}
+	if !isPublicAddr(ip) {
+		return fmt.Errorf("%w %s", errNonPublicAddress, ip)
+	}
+	return nil
+}
+
+func isPublicAddr(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	if !ip.IsGlobalUnicast() || ip.IsPrivate() {
+		return false
+	}
+	for _, prefix := range nonPublicPrefixes {
+		if prefix.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *NewsScraperService) fetchArticle(ctx context.Context, rawURL string) (*extract.Article, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkArticleURL(req.URL); err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", s.config.ScraperUserAgent)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml;q=0.9,*/*;q=0.5")
+	req.Header.Set("Accept-Encoding", "gzip, br")
+
+	resp, err := s.articleClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	contentType := resp.Header.Get("Content-Type")
+	if contentType != "" && !strings.Contains(contentType, "html") {
+		return nil, fmt.Errorf("unexpected content type %q", contentType)
+	}
+
+	body, err := readBody(resp, s.config.ScraperMaxBodyBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	reader, err := charset.NewReader(bytes.NewReader(body), contentType)
+	if err != nil {
+		return nil, err
+	}
+
+	// Resolve relative links against the final URL after redirects
+	return extract.Extract(reader, resp.Request.URL)
+}
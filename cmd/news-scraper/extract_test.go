@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+
+	"news-aggregator/pkg/config"
+)
+
+func TestIsPublicAddr(t *testing.T) {
+	tests := []struct {
+		addr string
+		want bool
+	}{
+		{"93.184.216.34", true},
+		{"2606:2800:220:1:248:1893:25c8:1946", true},
+		{"127.0.0.1", false},
+		{"::1", false},
+		{"10.1.2.3", false},
+		{"172.28.0.10", false},
+		{"192.168.1.1", false},
+		{"169.254.169.254", false},
+		{"fe80::1", false},
+		{"fd00::1", false},
+		{"0.0.0.0", false},
+		{"100.64.0.1", false},
+		{"224.0.0.1", false},
+		{"255.255.255.255", false},
+		{"::ffff:127.0.0.1", false},
+		{"::ffff:169.254.169.254", false},
+		{"64:ff9b::a9fe:a9fe", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.addr, func(t *testing.T) {
+			if got := isPublicAddr(netip.MustParseAddr(tt.addr)); got != tt.want {
+				t.Errorf("isPublicAddr(%s) = %v, want %v", tt.addr, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFetchArticleRefusesInternalTargets(t *testing.T) {
+	internal := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte("<html><body><p>secret</p></body></html>"))
+	}))
+	defer internal.Close()
+	_, port, _ := net.SplitHostPort(internal.Listener.Addr().String())
+
+	s := &NewsScraperService{
+		articleClient: newArticleClient(&config.Config{ScraperHTTPTimeout: 5}),
+		config:        &config.Config{ScraperMaxBodyBytes: 1 << 20},
+	}
+
+	tests := []struct {
+		name string
+		url  string
+	}{
+		{"loopback", internal.URL},
+		{"localhost name", "http://localhost:" + port},
+		{"metadata", "http://169.254.169.254/latest/meta-data/"},
+		{"file scheme", "file:///etc/passwd"},
+		{"ftp scheme", "ftp://example.com/article"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := s.fetchArticle(context.Background(), tt.url); err == nil {
+				t.Errorf("fetchArticle(%s) succeeded", tt.url)
+			}
+		})
+	}
+
+	if _, err := s.fetchArticle(context.Background(), internal.URL); !errors.Is(err, errNonPublicAddress) {
+		t.Errorf("fetchArticle() error = %v, want errNonPublicAddress", err)
+	}
+}
+
+func TestArticleRedirectSchemes(t *testing.T) {
+	client := newArticleClient(&config.Config{ScraperHTTPTimeout: 5})
+	via := []*http.Request{httptest.NewRequest(http.MethodGet, "https://example.com/a", nil)}
+
+	for target, ok := range map[string]bool{
+		"https://example.com/b":     true,
+		"http://example.com/b":      true,
+		"file:///etc/passwd":        false,
+		"gopher://127.0.0.1:6379/_": false,
+	} {
+		err := client.CheckRedirect(httptest.NewRequest(http.MethodGet, target, nil), via)
+		if (err == nil) != ok {
+			t.Errorf("redirect to %s: error = %v", target, err)
+		}
+	}
+
+	many := make([]*http.Request, maxArticleRedirects)
+	if err := client.CheckRedirect(via[0], many); err == nil {
+		t.Error("redirect chain was not cut off")
+	}
+}
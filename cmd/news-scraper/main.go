@@ -2,10 +2,10 @@ package main
 
 import (
 	"context"
-	"encoding/xml"
+	"encoding/json"
 	"fmt"
 	"net/http"
-	"sync"
+	"strings"
 	"time"
 
 	"github.com/segmentio/kafka-go"
@@ -14,33 +14,26 @@ import (
 	"gorm.io/gorm"
 
 	"news-aggregator/pkg/config"
+	"news-aggregator/pkg/events"
+	"news-aggregator/pkg/feed"
+	"news-aggregator/pkg/metrics"
 	"news-aggregator/pkg/models"
 )
 
-type RSS struct {
-	XMLName xml.Name `xml:"rss"`
-	Channel Channel  `xml:"channel"`
-}
-
-type Channel struct {
-	Title       string `xml:"title"`
-	Description string `xml:"description"`
-	Items       []Item `xml:"item"`
-}
-
-type Item struct {
-	Title       string `xml:"title"`
-	Description string `xml:"description"`
-	Link        string `xml:"link"`
-	PubDate     string `xml:"pubDate"`
-}
+const serviceName = "news-scraper"
 
 type NewsScraperService struct {
-	db       *gorm.DB
-	kafka    *kafka.Writer
-	config   *config.Config
-	logger   *zap.Logger
-	shutdown chan bool
+	db         *gorm.DB
+	kafka      *kafka.Writer
+	httpClient *http.Client
+	// articleClient fetches the pages feed items link to, which are not
+	// vetted like sources are
+	articleClient *http.Client
+	config        *config.Config
+	// extractJobs is nil when article extraction is turned off
+	extractJobs chan extractJob
+	logger      *zap.Logger
+	shutdown    chan bool
 }
 
 func main() {
@@ -57,137 +50,237 @@ func main() {
 	}
 
 	// Auto migrate
-	db.AutoMigrate(&models.News{})
+	if err := migrate(db); err != nil {
+		logger.Fatal("Failed to migrate database", zap.Error(err))
+	}
 
-	// Kafka writer
+	if err := seedSources(db, cfg.NewsSources); err != nil {
+		logger.Fatal("Failed to seed news sources", zap.Error(err))
+	}
+
+	if err := metrics.RegisterDB(db, "postgres"); err != nil {
+		logger.Error("Failed to register database metrics", zap.Error(err))
+	}
+
+	// Kafka writer; the topic is set per message by the outbox relay and the
+	// key hash keeps every event for one article on the same partition
 	kafkaWriter := &kafka.Writer{
-		Addr:     kafka.TCP(cfg.KafkaBrokers...),
-		Topic:    "news_updates",
-		Balancer: &kafka.LeastBytes{},
+		Addr:         kafka.TCP(cfg.KafkaBrokers...),
+		Balancer:     &kafka.Hash{},
+		RequiredAcks: kafka.RequireAll,
+		BatchTimeout: 50 * time.Millisecond,
 	}
 	defer kafkaWriter.Close()
 
 	service := &NewsScraperService{
-		db:       db,
-		kafka:    kafkaWriter,
-		config:   cfg,
-		logger:   logger,
-		shutdown: make(chan bool),
+		db:            db,
+		kafka:         kafkaWriter,
+		httpClient:    newHTTPClient(cfg),
+		articleClient: newArticleClient(cfg),
+		config:        cfg,
+		logger:        logger,
+		shutdown:      make(chan bool),
 	}
 
-	// Start scraping with goroutines
+	// Start the per-source polling scheduler
+	service.startExtractors()
+	go service.startOutboxRelay()
 	go service.startScraping()
+	go service.serveHTTP()
 
 	logger.Info("News scraper service started")
 	<-service.shutdown
 }
 
-func (s *NewsScraperService) startScraping() {
-	ticker := time.NewTicker(5 * time.Minute) // Scrape every 5 minutes
-	defer ticker.Stop()
+// serveHTTP exposes metrics and a health check; the scraper has no API of
+// its own.
+func (s *NewsScraperService) serveHTTP() {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics.Handler())
+	mux.HandleFunc("/health", s.healthCheck)
 
-	// Initial scrape
-	s.scrapeAllSources()
+	s.logger.Info("Metrics server starting", zap.String("port", s.config.ScraperPort))
+	if err := http.ListenAndServe(":"+s.config.ScraperPort, mux); err != nil {
+		s.logger.Fatal("Metrics server failed", zap.Error(err))
+	}
+}
 
-	for {
-		select {
-		case <-ticker.C:
-			s.scrapeAllSources()
-		case <-s.shutdown:
-			return
-		}
+func (s *NewsScraperService) healthCheck(w http.ResponseWriter, r *http.Request) {
+	status := map[string]interface{}{
+		"status":    "healthy",
+		"service":   serviceName,
+		"timestamp": time.Now().Unix(),
 	}
+	code := http.StatusOK
+
+	sqlDB, err := s.db.DB()
+	if err == nil {
+		err = sqlDB.PingContext(r.Context())
+	}
+	if err != nil {
+		status["status"] = "unhealthy"
+		status["error"] = "database ping failed"
+		code = http.StatusServiceUnavailable
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(status)
 }
 
-func (s *NewsScraperService) scrapeAllSources() {
-	s.logger.Info("Starting news scraping cycle")
+func (s *NewsScraperService) scrapeSource(source *models.Source) *fetchResult {
+	s.logger.Info("Scraping source", zap.String("url", source.URL))
 
-	var wg sync.WaitGroup
-	for _, source := range s.config.NewsSources {
-		if source == "" {
-			continue
-		}
+	start := time.Now()
+	resp, body, err := s.fetchFeed(context.Background(), source)
+	fetchDuration.WithLabelValues(sourceLabel(source)).Observe(time.Since(start).Seconds())
+	if resp == nil {
+		s.logger.Error("Failed to fetch feed", zap.String("url", source.URL), zap.Error(err))
+		return &fetchResult{Err: err}
+	}
 
-		wg.Add(1)
-		go func(url string) {
-			defer wg.Done()
-			s.scrapeSource(url)
-		}(source)
+	result := &fetchResult{
+		StatusCode:   resp.StatusCode,
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+		MaxAge:       parseMaxAge(resp.Header.Get("Cache-Control")),
+		RetryAfter:   parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
 	}
 
-	wg.Wait()
-	s.logger.Info("News scraping cycle completed")
-}
+	if resp.StatusCode == http.StatusNotModified {
+		s.logger.Info("Feed not modified", zap.String("url", source.URL))
+		return result
+	}
 
-func (s *NewsScraperService) scrapeSource(url string) {
-	s.logger.Info("Scraping source", zap.String("url", url))
+	if resp.StatusCode != http.StatusOK {
+		result.Err = fmt.Errorf("unexpected status %d", resp.StatusCode)
+		s.logger.Error("Failed to fetch feed", zap.String("url", source.URL), zap.Error(result.Err))
+		return result
+	}
+
+	if err != nil {
+		s.logger.Error("Failed to read feed", zap.String("url", source.URL), zap.Error(err))
+		result.Err = err
+		return result
+	}
 
-	resp, err := http.Get(url)
+	parsed, err := feed.Parse(body)
 	if err != nil {
-		s.logger.Error("Failed to fetch RSS", zap.String("url", url), zap.Error(err))
-		return
+		s.logger.Error("Failed to parse feed", zap.String("url", source.URL), zap.Error(err))
+		result.Err = err
+		return result
 	}
-	defer resp.Body.Close()
+	result.FeedTTL = parsed.TTL
 
-	var rss RSS
-	if err := xml.NewDecoder(resp.Body).Decode(&rss); err != nil {
-		s.logger.Error("Failed to parse RSS", zap.String("url", url), zap.Error(err))
-		return
+	// Sources added without a display name take the feed title
+	if source.Name == "" && parsed.Title != "" {
+		source.Name = parsed.Title
+		s.db.Model(source).Update("name", source.Name)
 	}
 
 	// Process each item
-	for _, item := range rss.Channel.Items {
+	for _, item := range parsed.Items {
 		if item.Link == "" || item.Title == "" {
 			continue
 		}
+		itemsParsed.WithLabelValues(sourceLabel(source)).Inc()
 
-		// Check if article already exists
-		var existingNews models.News
-		if err := s.db.Where("url = ?", item.Link).First(&existingNews).Error; err == nil {
-			continue // Article already exists
-		}
+		// Create news entry
+		news := newsFromItem(source, item)
 
-		// Parse published date
-		pubTime := time.Now()
-		if item.PubDate != "" {
-			if parsed, err := time.Parse(time.RFC1123, item.PubDate); err == nil {
-				pubTime = parsed
+		// Known articles only produce an event when the feed edited them.
+		// Articles a moderator deleted stay deleted.
+		var existingNews models.News
+		if err := s.db.Unscoped().Where("url = ?", item.Link).First(&existingNews).Error; err == nil {
+			itemsDuplicate.WithLabelValues(sourceLabel(source)).Inc()
+			if existingNews.DeletedAt.Valid {
+				continue
 			}
+			if existingNews.Title != news.Title || existingNews.Description != news.Description {
+				s.updateNews(existingNews, news, source)
+			}
+			continue
 		}
 
-		// Create news entry
-		news := models.News{
-			Title:       item.Title,
-			Description: item.Description,
-			URL:         item.Link,
-			Source:      rss.Channel.Title,
-			PublishedAt: pubTime,
-		}
-
-		// Save to database
-		if err := s.db.Create(&news).Error; err != nil {
+		// Save the article and its event atomically; the outbox relay
+		// publishes the event to Kafka
+		err := s.db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Create(&news).Error; err != nil {
+				return err
+			}
+			event, err := newsEvent(events.TypeNewsCreated, news, source)
+			if err != nil {
+				return err
+			}
+			return tx.Create(event).Error
+		})
+		if err != nil {
 			s.logger.Error("Failed to save news", zap.Error(err))
 			continue
 		}
 
-		// Send to Kafka
-		s.sendToKafka(news)
+		s.queueExtraction(source, news)
+		result.NewItems++
+		itemsNew.WithLabelValues(sourceLabel(source)).Inc()
 
 		s.logger.Info("Thu thập tin: " + news.Title)
 	}
+
+	return result
+}
+
+func (s *NewsScraperService) updateNews(existing, news models.News, source *models.Source) {
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&existing).Updates(map[string]interface{}{
+			"title":       news.Title,
+			"description": news.Description,
+		}).Error; err != nil {
+			return err
+		}
+		existing.Title = news.Title
+		existing.Description = news.Description
+
+		event, err := newsEvent(events.TypeNewsUpdated, existing, source)
+		if err != nil {
+			return err
+		}
+		return tx.Create(event).Error
+	})
+	if err != nil {
+		s.logger.Error("Failed to update news", zap.Uint("newsID", existing.ID), zap.Error(err))
+	}
 }
 
-func (s *NewsScraperService) sendToKafka(news models.News) {
-	message := kafka.Message{
-		Key: []byte(fmt.Sprintf("news_%d", news.ID)),
-		Value: []byte(fmt.Sprintf(`{"id":%d,"title":"%s","url":"%s","source":"%s"}`,
-			news.ID, news.Title, news.URL, news.Source)),
+// newsFromItem maps a normalized feed item onto the stored article.
+func newsFromItem(source *models.Source, item feed.Item) models.News {
+	description := item.Summary
+	if description == "" {
+		description = item.Content
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
+	pubTime := item.Published
+	if pubTime.IsZero() {
+		pubTime = item.Updated
+	}
+	if pubTime.IsZero() {
+		pubTime = time.Now()
+	}
+
+	return models.News{
+		Title:        item.Title,
+		Description:  description,
+		Author:       strings.Join(item.Authors, ", "),
+		URL:          item.Link,
+		SourceID:     &source.ID,
+		SearchConfig: source.SearchConfig,
+		PublishedAt:  pubTime,
+	}
+}
 
-	if err := s.kafka.WriteMessages(ctx, message); err != nil {
-		s.logger.Error("Failed to send message to Kafka", zap.Error(err))
+func newsEvent(eventType string, news models.News, source *models.Source) (*models.OutboxEvent, error) {
+	env, err := events.New(eventType, serviceName, events.NewsPayloadFrom(news, source))
+	if err != nil {
+		return nil, err
 	}
+	return events.Outbox(env, events.NewsKey(news.ID))
 }
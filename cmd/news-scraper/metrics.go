@@ -0,0 +1,50 @@
+package main
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"news-aggregator/pkg/models"
+)
+
+var (
+	fetchDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "scraper_fetch_duration_seconds",
+		Help:    "Time taken to download a source's feed.",
+		Buckets: []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30},
+	}, []string{"source"})
+
+	fetchFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "scraper_fetch_failures_total",
+		Help: "Feed fetches that failed to download or parse.",
+	}, []string{"source"})
+
+	itemsParsed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "scraper_items_parsed_total",
+		Help: "Usable items found in feeds.",
+	}, []string{"source"})
+
+	itemsNew = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "scraper_items_new_total",
+		Help: "Items stored as new articles.",
+	}, []string{"source"})
+
+	itemsDuplicate = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "scraper_items_duplicate_total",
+		Help: "Items already stored, whether or not the feed edited them.",
+	}, []string{"source"})
+
+	kafkaPublishErrors = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "scraper_kafka_publish_errors_total",
+		Help: "Outbox batches Kafka did not accept.",
+	})
+)
+
+// sourceLabel names a source in metrics. Sources added without a name are
+// known by their URL until the first fetch gives them the feed title.
+func sourceLabel(source *models.Source) string {
+	if source.Name != "" {
+		return source.Name
+	}
+	return source.URL
+}
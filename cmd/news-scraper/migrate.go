@@ -0,0 +1,83 @@
+package main
+
+import (
+	"strings"
+
+	"gorm.io/gorm"
+
+	"news-aggregator/pkg/models"
+)
+
+func migrate(db *gorm.DB) error {
+	if err := db.AutoMigrate(&models.Source{}, &models.News{}, &models.OutboxEvent{}); err != nil {
+		return err
+	}
+
+	// Articles scraped before the source registry existed copied the channel
+	// title into news.source. Attach them to a source row before dropping it.
+	if db.Migrator().HasColumn("news", "source") {
+		err := db.Transaction(func(tx *gorm.DB) error {
+			var names []string
+			if err := tx.Table("news").
+				Where("source_id IS NULL").
+				Distinct("source").
+				Pluck("source", &names).Error; err != nil {
+				return err
+			}
+
+			for _, name := range names {
+				var source models.Source
+				if err := tx.Where("name = ?", name).First(&source).Error; err != nil {
+					// Unknown feed URL: keep the name on a disabled placeholder
+					// an admin can fix up later.
+					source = models.Source{
+						URL:  "legacy:" + name,
+						Name: name,
+					}
+					if err := tx.Create(&source).Error; err != nil {
+						return err
+					}
+				}
+
+				if err := tx.Table("news").
+					Where("source_id IS NULL AND source = ?", name).
+					Update("source_id", source.ID).Error; err != nil {
+					return err
+				}
+			}
+
+			return tx.Migrator().DropColumn("news", "source")
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return models.MigrateSearch(db)
+}
+
+// seedSources registers the feeds listed in NEWS_SOURCES so existing
+// deployments keep scraping them. Sources already in the table, including
+// ones an admin disabled or deleted, are left untouched.
+func seedSources(db *gorm.DB, urls []string) error {
+	for _, url := range urls {
+		url = strings.TrimSpace(url)
+		if url == "" {
+			continue
+		}
+
+		var count int64
+		if err := db.Unscoped().Model(&models.Source{}).Where("url = ?", url).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			continue
+		}
+
+		if err := db.Create(&models.Source{URL: url, Enabled: true}).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
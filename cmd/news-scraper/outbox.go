@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"news-aggregator/pkg/events"
+	"news-aggregator/pkg/models"
+)
+
+const (
+	outboxPollInterval = time.Second
+	outboxBatchSize    = 100
+	outboxMaxBackoff   = 5 * time.Minute
+	// Published rows are kept for a while to help trace delivery issues.
+	outboxRetention = 7 * 24 * time.Hour
+)
+
+// startOutboxRelay publishes pending outbox rows until shutdown. Rows are
+// locked with SKIP LOCKED so several scraper replicas can relay side by side
+// without publishing the same row twice.
+func (s *NewsScraperService) startOutboxRelay() {
+	ticker := time.NewTicker(outboxPollInterval)
+	defer ticker.Stop()
+
+	lastCleanup := time.Time{}
+	for {
+		// Drain everything that is due before waiting again
+		for {
+			if s.relayOutboxBatch() < outboxBatchSize {
+				break
+			}
+		}
+
+		if time.Since(lastCleanup) > time.Hour {
+			s.cleanupOutbox()
+			lastCleanup = time.Now()
+		}
+
+		select {
+		case <-ticker.C:
+		case <-s.shutdown:
+			return
+		}
+	}
+}
+
+// relayOutboxBatch publishes one batch and returns how many rows it handled.
+func (s *NewsScraperService) relayOutboxBatch() int {
+	var handled int
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var pending []models.OutboxEvent
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("published_at IS NULL AND next_attempt_at <= ?", time.Now()).
+			Order("id").
+			Limit(outboxBatchSize).
+			Find(&pending).Error; err != nil {
+			return err
+		}
+		handled = len(pending)
+		if len(pending) == 0 {
+			return nil
+		}
+
+		messages := make([]kafka.Message, 0, len(pending))
+		ids := make([]uint, 0, len(pending))
+		for _, event := range pending {
+			messages = append(messages, kafka.Message{
+				Topic:   event.Topic,
+				Key:     []byte(event.Key),
+				Value:   event.Payload,
+				Headers: events.Headers(event.EventID, event.EventType, event.SchemaVersion),
+			})
+			ids = append(ids, event.ID)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		if err := s.kafka.WriteMessages(ctx, messages...); err != nil {
+			s.logger.Error("Failed to publish outbox events", zap.Int("count", len(pending)), zap.Error(err))
+			kafkaPublishErrors.Inc()
+
+			// The whole batch is retried: kafka-go may have written part of it,
+			// which consumers absorb by deduplicating on event_id.
+			for _, event := range pending {
+				if err := tx.Model(&event).Updates(map[string]interface{}{
+					"attempts":        event.Attempts + 1,
+					"last_error":      err.Error(),
+					"next_attempt_at": time.Now().Add(outboxBackoff(event.Attempts + 1)),
+				}).Error; err != nil {
+					return err
+				}
+			}
+			handled = 0
+			return nil
+		}
+
+		return tx.Model(&models.OutboxEvent{}).
+			Where("id IN ?", ids).
+			Updates(map[string]interface{}{
+				"published_at": time.Now(),
+				"last_error":   "",
+			}).Error
+	})
+	if err != nil {
+		s.logger.Error("Outbox relay failed", zap.Error(err))
+		return 0
+	}
+
+	return handled
+}
+
+func (s *NewsScraperService) cleanupOutbox() {
+	if err := s.db.Where("published_at < ?", time.Now().Add(-outboxRetention)).
+		Delete(&models.OutboxEvent{}).Error; err != nil {
+		s.logger.Error("Failed to clean up outbox", zap.Error(err))
+	}
+}
+
+// outboxBackoff doubles from one second up to outboxMaxBackoff.
+func outboxBackoff(attempts int) time.Duration {
+	backoff := time.Second
+	for i := 1; i < attempts && backoff < outboxMaxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > outboxMaxBackoff {
+		return outboxMaxBackoff
+	}
+	return backoff
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"news-aggregator/pkg/models"
+)
+
+const (
+	// scheduleTick is how often the scheduler looks for sources that are due.
+	scheduleTick = 15 * time.Second
+	// claimLease pushes next_fetch_at out while a source is being fetched so
+	// that neither this loop nor another scraper replica picks it up twice.
+	// A crashed worker simply lets the lease expire.
+	claimLease = 10 * time.Minute
+	// maxBackoffShift caps the exponential failure backoff at base << 10.
+	maxBackoffShift = 10
+)
+
+// fetchResult carries what a single fetch learned about a source, which the
+// scheduler uses to decide when to poll it next.
+type fetchResult struct {
+	StatusCode   int
+	ETag         string
+	LastModified string
+	NewItems     int
+	FeedTTL      time.Duration
+	MaxAge       time.Duration
+	RetryAfter   time.Duration
+	Err          error
+}
+
+func (s *NewsScraperService) startScraping() {
+	workers := s.config.ScraperWorkers
+	if workers < 1 {
+		workers = 1
+	}
+
+	jobs := make(chan models.Source)
+	for i := 0; i < workers; i++ {
+		go s.scrapeWorker(jobs)
+	}
+
+	ticker := time.NewTicker(scheduleTick)
+	defer ticker.Stop()
+
+	for {
+		s.dispatchDueSources(jobs, workers)
+
+		select {
+		case <-ticker.C:
+		case <-s.shutdown:
+			close(jobs)
+			return
+		}
+	}
+}
+
+func (s *NewsScraperService) scrapeWorker(jobs <-chan models.Source) {
+	for source := range jobs {
+		result := s.scrapeSource(&source)
+		if result.Err != nil {
+			fetchFailures.WithLabelValues(sourceLabel(&source)).Inc()
+		}
+		s.recordFetch(&source, result)
+	}
+}
+
+// dispatchDueSources hands sources whose next_fetch_at has passed to the
+// worker pool. Sends block while all workers are busy, so at most a couple of
+// batches are claimed ahead of the pool.
+func (s *NewsScraperService) dispatchDueSources(jobs chan<- models.Source, workers int) {
+	now := time.Now()
+
+	var due []models.Source
+	if err := s.db.Where("enabled = ? AND (next_fetch_at IS NULL OR next_fetch_at <= ?)", true, now).
+		Order("next_fetch_at NULLS FIRST").
+		Limit(workers * 2).
+		Find(&due).Error; err != nil {
+		s.logger.Error("Failed to load due sources", zap.Error(err))
+		return
+	}
+
+	for _, source := range due {
+		if !s.claimSource(&source, now) {
+			continue
+		}
+
+		select {
+		case jobs <- source:
+		case <-s.shutdown:
+			return
+		}
+	}
+}
+
+func (s *NewsScraperService) claimSource(source *models.Source, now time.Time) bool {
+	result := s.db.Model(&models.Source{}).
+		Where("id = ? AND (next_fetch_at IS NULL OR next_fetch_at <= ?)", source.ID, now).
+		Update("next_fetch_at", now.Add(claimLease))
+	if result.Error != nil {
+		s.logger.Error("Failed to claim source", zap.Uint("sourceID", source.ID), zap.Error(result.Error))
+		return false
+	}
+	return result.RowsAffected == 1
+}
+
+func (s *NewsScraperService) recordFetch(source *models.Source, result *fetchResult) {
+	now := time.Now()
+	interval, next := s.nextInterval(source, result)
+
+	updates := map[string]interface{}{
+		"last_fetched_at":   now,
+		"last_fetch_status": models.FetchStatusOK,
+		"last_fetch_error":  "",
+		"fetch_interval":    int(interval.Seconds()),
+		"next_fetch_at":     now.Add(next),
+		"failure_count":     0,
+	}
+	if result.StatusCode == http.StatusOK && result.Err == nil {
+		updates["e_tag"] = result.ETag
+		updates["last_modified"] = result.LastModified
+	}
+	if result.Err != nil {
+		updates["last_fetch_status"] = models.FetchStatusError
+		updates["last_fetch_error"] = result.Err.Error()
+		updates["failure_count"] = source.FailureCount + 1
+	}
+
+	if err := s.db.Model(source).Updates(updates).Error; err != nil {
+		s.logger.Error("Failed to record fetch status", zap.Uint("sourceID", source.ID), zap.Error(err))
+	}
+}
+
+// nextInterval returns the source's adaptive polling interval after this
+// fetch together with the delay until the next fetch. The two differ only
+// when the fetch failed: failures back off without disturbing the interval
+// learned from successful fetches.
+func (s *NewsScraperService) nextInterval(source *models.Source, result *fetchResult) (time.Duration, time.Duration) {
+	minInterval := time.Duration(s.config.ScraperMinInterval) * time.Second
+	maxInterval := time.Duration(s.config.ScraperMaxInterval) * time.Second
+
+	base := time.Duration(source.PollInterval) * time.Second
+	if base <= 0 {
+		base = 5 * time.Minute
+	}
+	current := time.Duration(source.FetchInterval) * time.Second
+	if current <= 0 {
+		current = base
+	}
+
+	if result.Err != nil {
+		if result.RetryAfter > 0 {
+			return current, clampDuration(result.RetryAfter, minInterval, maxInterval)
+		}
+
+		shift := source.FailureCount
+		if shift > maxBackoffShift {
+			shift = maxBackoffShift
+		}
+		return current, clampDuration(base<<shift, minInterval, maxInterval)
+	}
+
+	// Busy feeds get polled more often, quiet ones less
+	if result.NewItems > 0 {
+		current /= 2
+	} else {
+		current = current * 3 / 2
+	}
+
+	// Never poll faster than the publisher asked us to
+	for _, hint := range []time.Duration{result.FeedTTL, result.MaxAge, result.RetryAfter} {
+		if current < hint {
+			current = hint
+		}
+	}
+
+	current = clampDuration(current, minInterval, maxInterval)
+	return current, current
+}
+
+func clampDuration(d, min, max time.Duration) time.Duration {
+	if d < min {
+		return min
+	}
+	if max > 0 && d > max {
+		return max
+	}
+	return d
+}
+
+// parseMaxAge extracts max-age from a Cache-Control header.
+func parseMaxAge(header string) time.Duration {
+	for _, directive := range strings.Split(header, ",") {
+		directive = strings.TrimSpace(strings.ToLower(directive))
+		if !strings.HasPrefix(directive, "max-age=") {
+			continue
+		}
+
+		seconds, err := strconv.Atoi(strings.Trim(strings.TrimPrefix(directive, "max-age="), `"`))
+		if err != nil || seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+	return 0
+}
+
+// parseRetryAfter accepts both forms of Retry-After: delay-seconds and an
+// HTTP date.
+func parseRetryAfter(header string, now time.Time) time.Duration {
+	header = strings.TrimSpace(header)
+	if header == "" {
+		return 0
+	}
+
+	if seconds, err := strconv.Atoi(header); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+
+	if at, err := http.ParseTime(header); err == nil && at.After(now) {
+		return at.Sub(now)
+	}
+	return 0
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"go.uber.org/zap"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+
+	"news-aggregator/pkg/config"
+	"news-aggregator/pkg/models"
+)
+
+// dryRunDB builds statements for Postgres without a server. The SET
+// clauses of every update are handed to capture.
+func dryRunDB(t *testing.T, capture func(columns []string)) *gorm.DB {
+	t.Helper()
+
+	db, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=localhost dbname=test"}), &gorm.Config{
+		DryRun:               true,
+		DisableAutomaticPing: true,
+	})
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+
+	err = db.Callback().Update().After("gorm:update").Register("test:capture", func(tx *gorm.DB) {
+		updates, ok := tx.Statement.Dest.(map[string]interface{})
+		if !ok {
+			return
+		}
+		var columns []string
+		for column := range updates {
+			columns = append(columns, column)
+		}
+		capture(columns)
+	})
+	if err != nil {
+		t.Fatalf("register callback: %v", err)
+	}
+	return db
+}
+
+func TestRecordFetchUpdatesExistingColumns(t *testing.T) {
+	tests := []struct {
+		name   string
+		result *fetchResult
+	}{
+		{"ok", &fetchResult{StatusCode: http.StatusOK, ETag: `"v1"`, LastModified: "Mon, 02 Jan 2006 15:04:05 GMT", NewItems: 3}},
+		{"not modified", &fetchResult{StatusCode: http.StatusNotModified}},
+		{"failed", &fetchResult{Err: errors.New("boom")}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var updated []string
+			db := dryRunDB(t, func(columns []string) { updated = columns })
+
+			s := &NewsScraperService{
+				db:     db,
+				config: &config.Config{ScraperMinInterval: 60, ScraperMaxInterval: 3600},
+				logger: zap.NewNop(),
+			}
+			s.recordFetch(&models.Source{ID: 1, PollInterval: 300}, tt.result)
+
+			if len(updated) == 0 {
+				t.Fatal("no update was issued")
+			}
+
+			stmt := &gorm.Statement{DB: db}
+			if err := stmt.Parse(&models.Source{}); err != nil {
+				t.Fatalf("parse schema: %v", err)
+			}
+			savedETag := false
+			for _, column := range updated {
+				field := stmt.Schema.LookUpField(column)
+				if field == nil || field.DBName != column {
+					t.Errorf("update sets %q, which is not a column of sources", column)
+					continue
+				}
+				savedETag = savedETag || field.Name == "ETag"
+			}
+
+			if tt.result.StatusCode == http.StatusOK && !savedETag {
+				t.Errorf("validators were not saved: %v", updated)
+			}
+		})
+	}
+}
@@ -13,8 +13,6 @@ type Config struct {
 	DatabaseURL     string
 	RedisURL        string
 	KafkaBrokers    []string
-	JWTSecret       string
-	JWTExpireHours  int
 	APIGatewayPort  string
 	NewsAPIPort     string
 	ScraperPort     string
@@ -22,6 +20,63 @@ type Config struct {
 	RateLimitReqs   int
 	RateLimitWindow int
 	NewsSources     []string
+	TrustedProxies  []string
+
+	APIKeyRateLimit   int
+	APIKeyMaxPerUser  int
+	APIKeySyncMinutes int
+
+	RateLimitTiers    string
+	RateLimitFailOpen bool
+
+	ScraperWorkers            int
+	ScraperMinInterval        int
+	ScraperMaxInterval        int
+	ScraperUserAgent          string
+	ScraperHTTPTimeout        int
+	ScraperMaxBodyBytes       int64
+	ScraperExtractConcurrency int
+
+	JWTAccessTTLMinutes  int
+	RefreshTokenTTLHours int
+	JWTSigningAlg        string
+	JWTKeyRotationHours  int
+	JWTKeyEncryptionKey  string
+	JWKSURL              string
+	JWKSCacheMinutes     int
+
+	AdminUsername string
+	AdminEmail    string
+	AdminPassword string
+
+	AppBaseURL                string
+	MailDriver                string
+	MailFrom                  string
+	MailDir                   string
+	SMTPHost                  string
+	SMTPPort                  string
+	SMTPUsername              string
+	SMTPPassword              string
+	PasswordResetTTLMinutes   int
+	EmailVerificationTTLHours int
+
+	LoginMaxFailures          int
+	LoginIPMaxFailures        int
+	LoginFailureWindowMinutes int
+	LoginLockoutMinutes       int
+
+	TOTPIssuer             string
+	MFAChallengeTTLMinutes int
+
+	OIDCProvidersFile   string
+	OIDCRedirectBaseURL string
+
+	AccountPurgeDelayHours int
+
+	GatewayRoutesFile          string
+	GatewayRoutesReloadSeconds int
+	GatewayDNSRefreshSeconds   int
+	GatewayMaxBodyBytes        int64
 }
 
 func Load() *Config {
@@ -33,8 +88,6 @@ func Load() *Config {
 		DatabaseURL:     buildDatabaseURL(),
 		RedisURL:        buildRedisURL(),
 		KafkaBrokers:    strings.Split(getEnv("KAFKA_BROKERS", "localhost:9092"), ","),
-		JWTSecret:       getEnv("JWT_SECRET", "default-secret"),
-		JWTExpireHours:  getEnvInt("JWT_EXPIRE_HOURS", 24),
 		APIGatewayPort:  getEnv("API_GATEWAY_PORT", "8080"),
 		NewsAPIPort:     getEnv("NEWS_API_PORT", "8081"),
 		ScraperPort:     getEnv("NEWS_SCRAPER_PORT", "8082"),
@@ -42,11 +95,84 @@ func Load() *Config {
 		RateLimitReqs:   getEnvInt("RATE_LIMIT_REQUESTS", 100),
 		RateLimitWindow: getEnvInt("RATE_LIMIT_WINDOW", 60),
 		NewsSources:     strings.Split(getEnv("NEWS_SOURCES", ""), ","),
+		TrustedProxies:  strings.Split(getEnv("TRUSTED_PROXIES", "127.0.0.1,::1"), ","),
+
+		APIKeyRateLimit:   getEnvInt("API_KEY_RATE_LIMIT", 1000),
+		APIKeyMaxPerUser:  getEnvInt("API_KEY_MAX_PER_USER", 10),
+		APIKeySyncMinutes: getEnvInt("API_KEY_SYNC_MINUTES", 5),
+
+		RateLimitTiers:    getEnv("RATE_LIMIT_TIERS", ""),
+		RateLimitFailOpen: getEnvBool("RATE_LIMIT_FAIL_OPEN", true),
+
+		ScraperWorkers:      getEnvInt("SCRAPER_WORKERS", 4),
+		ScraperMinInterval:  getEnvInt("SCRAPER_MIN_INTERVAL", 60),
+		ScraperMaxInterval:  getEnvInt("SCRAPER_MAX_INTERVAL", 6*60*60),
+		ScraperUserAgent:    getEnv("SCRAPER_USER_AGENT", "NewsAggregatorBot/1.0"),
+		ScraperHTTPTimeout:  getEnvInt("SCRAPER_HTTP_TIMEOUT", 30),
+		ScraperMaxBodyBytes: int64(getEnvInt("SCRAPER_MAX_BODY_BYTES", 10<<20)),
+
+		ScraperExtractConcurrency: getEnvInt("SCRAPER_EXTRACT_CONCURRENCY", 2),
+
+		JWTAccessTTLMinutes:  getEnvInt("JWT_ACCESS_TTL_MINUTES", legacyAccessTTLMinutes()),
+		RefreshTokenTTLHours: getEnvInt("REFRESH_TOKEN_TTL_HOURS", 30*24),
+		JWTSigningAlg:        getEnv("JWT_SIGNING_ALG", "RS256"),
+		JWTKeyRotationHours:  getEnvInt("JWT_KEY_ROTATION_HOURS", 30*24),
+		JWTKeyEncryptionKey:  getEnv("JWT_KEY_ENCRYPTION_KEY", ""),
+		JWKSCacheMinutes:     getEnvInt("JWKS_CACHE_MINUTES", 5),
+
+		AdminUsername: getEnv("ADMIN_USERNAME", ""),
+		AdminEmail:    getEnv("ADMIN_EMAIL", ""),
+		AdminPassword: getEnv("ADMIN_PASSWORD", ""),
+
+		AppBaseURL:                getEnv("APP_BASE_URL", "http://localhost:3000"),
+		MailDriver:                getEnv("MAIL_DRIVER", "log"),
+		MailFrom:                  getEnv("MAIL_FROM", "News Aggregator <no-reply@localhost>"),
+		MailDir:                   getEnv("MAIL_DIR", "mail"),
+		SMTPHost:                  getEnv("SMTP_HOST", "localhost"),
+		SMTPPort:                  getEnv("SMTP_PORT", "1025"),
+		SMTPUsername:              getEnv("SMTP_USERNAME", ""),
+		SMTPPassword:              getEnv("SMTP_PASSWORD", ""),
+		PasswordResetTTLMinutes:   getEnvInt("PASSWORD_RESET_TTL_MINUTES", 30),
+		EmailVerificationTTLHours: getEnvInt("EMAIL_VERIFICATION_TTL_HOURS", 48),
+
+		LoginMaxFailures:          getEnvInt("LOGIN_MAX_FAILURES", 5),
+		LoginIPMaxFailures:        getEnvInt("LOGIN_IP_MAX_FAILURES", 20),
+		LoginFailureWindowMinutes: getEnvInt("LOGIN_FAILURE_WINDOW_MINUTES", 15),
+		LoginLockoutMinutes:       getEnvInt("LOGIN_LOCKOUT_MINUTES", 15),
+
+		TOTPIssuer:             getEnv("TOTP_ISSUER", "News Aggregator"),
+		MFAChallengeTTLMinutes: getEnvInt("MFA_CHALLENGE_TTL_MINUTES", 5),
+
+		OIDCProvidersFile:   getEnv("OIDC_PROVIDERS_FILE", ""),
+		OIDCRedirectBaseURL: getEnv("OIDC_REDIRECT_BASE_URL", "http://localhost:8080"),
+
+		AccountPurgeDelayHours: getEnvInt("ACCOUNT_PURGE_DELAY_HOURS", 0),
+
+		GatewayRoutesFile:          getEnv("GATEWAY_ROUTES_FILE", ""),
+		GatewayRoutesReloadSeconds: getEnvInt("GATEWAY_ROUTES_RELOAD_SECONDS", 5),
+		GatewayDNSRefreshSeconds:   getEnvInt("GATEWAY_DNS_REFRESH_SECONDS", 30),
+		GatewayMaxBodyBytes:        int64(getEnvInt("GATEWAY_MAX_BODY_BYTES", 10<<20)),
 	}
 
+	cfg.JWKSURL = getEnv("JWKS_URL", "http://localhost:"+cfg.AuthServicePort+"/.well-known/jwks.json")
+
 	return cfg
 }
 
+// legacyAccessTTLMinutes reads JWT_EXPIRE_HOURS, which set the access token
+// lifetime before refresh tokens existed, as the default for
+// JWT_ACCESS_TTL_MINUTES.
+func legacyAccessTTLMinutes() int {
+	hours := getEnvInt("JWT_EXPIRE_HOURS", 0)
+	if hours <= 0 {
+		return 15
+	}
+	if os.Getenv("JWT_ACCESS_TTL_MINUTES") == "" {
+		log.Println("JWT_EXPIRE_HOURS is deprecated, use JWT_ACCESS_TTL_MINUTES with refresh tokens instead")
+	}
+	return hours * 60
+}
+
 func buildDatabaseURL() string {
 	host := getEnv("DB_HOST", "localhost")
 	port := getEnv("DB_PORT", "5432")
@@ -84,3 +210,12 @@ func getEnvInt(key string, fallback int) int {
 	}
 	return fallback
 }
+
+func getEnvBool(key string, fallback bool) bool {
+	if value := os.Getenv(key); value != "" {
+		if boolVal, err := strconv.ParseBool(value); err == nil {
+			return boolVal
+		}
+	}
+	return fallback
+}
@@ -0,0 +1,153 @@
+package events
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/segmentio/kafka-go"
+
+	"news-aggregator/pkg/models"
+)
+
+const (
+	NewsTopic = "news_updates"
+
+	// SchemaVersion is bumped on any breaking change to the envelope or a
+	// payload. Consumers reject versions newer than the one they know.
+	SchemaVersion = 1
+
+	TypeNewsCreated = "news.created"
+	TypeNewsUpdated = "news.updated"
+	TypeNewsDeleted = "news.deleted"
+
+	HeaderEventID       = "event_id"
+	HeaderEventType     = "event_type"
+	HeaderSchemaVersion = "schema_version"
+	HeaderContentType   = "content_type"
+
+	contentTypeJSON = "application/json"
+)
+
+var ErrUnsupportedVersion = errors.New("events: unsupported schema version")
+
+// Envelope wraps every event published on the topic.
+type Envelope struct {
+	ID         string          `json:"id"`
+	Type       string          `json:"type"`
+	Version    int             `json:"version"`
+	OccurredAt time.Time       `json:"occurred_at"`
+	Producer   string          `json:"producer"`
+	Payload    json.RawMessage `json:"payload"`
+}
+
+// NewsPayload is carried by news.created and news.updated.
+type NewsPayload struct {
+	ID          uint      `json:"id"`
+	Title       string    `json:"title"`
+	Description string    `json:"description"`
+	URL         string    `json:"url"`
+	Author      string    `json:"author,omitempty"`
+	SourceID    *uint     `json:"source_id,omitempty"`
+	Source      string    `json:"source,omitempty"`
+	PublishedAt time.Time `json:"published_at"`
+}
+
+// NewsDeletedPayload is carried by news.deleted.
+type NewsDeletedPayload struct {
+	ID  uint   `json:"id"`
+	URL string `json:"url"`
+}
+
+// New builds an envelope with a fresh event ID around payload.
+func New(eventType, producer string, payload interface{}) (*Envelope, error) {
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("events: encode %s payload: %w", eventType, err)
+	}
+
+	return &Envelope{
+		ID:         uuid.NewString(),
+		Type:       eventType,
+		Version:    SchemaVersion,
+		OccurredAt: time.Now().UTC(),
+		Producer:   producer,
+		Payload:    raw,
+	}, nil
+}
+
+func NewsPayloadFrom(news models.News, source *models.Source) NewsPayload {
+	payload := NewsPayload{
+		ID:          news.ID,
+		Title:       news.Title,
+		Description: news.Description,
+		URL:         news.URL,
+		Author:      news.Author,
+		SourceID:    news.SourceID,
+		PublishedAt: news.PublishedAt,
+	}
+	if source != nil {
+		payload.Source = source.Name
+	}
+	return payload
+}
+
+// NewsKey is the message key for events about one article, which keeps
+// them on one partition and therefore in order.
+func NewsKey(newsID uint) string {
+	return fmt.Sprintf("news_%d", newsID)
+}
+
+func (e *Envelope) Encode() ([]byte, error) {
+	return json.Marshal(e)
+}
+
+// DecodePayload unmarshals the payload into v, e.g. a *NewsPayload for
+// news.created.
+func (e *Envelope) DecodePayload(v interface{}) error {
+	return json.Unmarshal(e.Payload, v)
+}
+
+// Headers describes the envelope in Kafka headers so consumers can route or
+// skip messages without decoding the body.
+func Headers(eventID, eventType string, version int) []kafka.Header {
+	return []kafka.Header{
+		{Key: HeaderEventID, Value: []byte(eventID)},
+		{Key: HeaderEventType, Value: []byte(eventType)},
+		{Key: HeaderSchemaVersion, Value: []byte(strconv.Itoa(version))},
+		{Key: HeaderContentType, Value: []byte(contentTypeJSON)},
+	}
+}
+
+// Decode parses a message from the topic.
+func Decode(msg kafka.Message) (*Envelope, error) {
+	var env Envelope
+	if err := json.Unmarshal(msg.Value, &env); err != nil {
+		return nil, fmt.Errorf("events: decode envelope: %w", err)
+	}
+	if env.Version < 1 || env.Version > SchemaVersion {
+		return nil, fmt.Errorf("%w: %d", ErrUnsupportedVersion, env.Version)
+	}
+	return &env, nil
+}
+
+// Outbox turns an envelope into a row for the transactional outbox.
+func Outbox(env *Envelope, key string) (*models.OutboxEvent, error) {
+	body, err := env.Encode()
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.OutboxEvent{
+		EventID:       env.ID,
+		EventType:     env.Type,
+		SchemaVersion: env.Version,
+		Topic:         NewsTopic,
+		Key:           key,
+		Payload:       body,
+		NextAttemptAt: time.Now(),
+	}, nil
+}
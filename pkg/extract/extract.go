@@ -0,0 +1,447 @@
+package extract
+
+import (
+	"errors"
+	"io"
+	"math"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// WordsPerMinute is the reading speed used to estimate ReadingTime.
+const WordsPerMinute = 200
+
+var ErrNoContent = errors.New("extract: no readable content found")
+
+// Article is the main content of a web page as found by Extract.
+type Article struct {
+	Title       string
+	Author      string
+	LeadImage   string
+	Text        string
+	HTML        string
+	WordCount   int
+	ReadingTime int // minutes
+}
+
+// The class/id heuristics follow Mozilla's Readability.
+var (
+	unlikelyCandidates = regexp.MustCompile(`(?i)-ad-|ai2html|banner|breadcrumbs|combx|comment|community|cover-wrap|disqus|extra|footer|gdpr|header|legends|menu|related|remark|replies|rss|shoutbox|sidebar|skyscraper|social|sponsor|supplemental|ad-break|agegate|pagination|pager|popup|yom-remote`)
+	maybeCandidate     = regexp.MustCompile(`(?i)and|article|body|column|content|main|shadow`)
+	positiveWeight     = regexp.MustCompile(`(?i)article|body|content|entry|hentry|h-entry|main|page|post|text|blog|story`)
+	negativeWeight     = regexp.MustCompile(`(?i)-ad-|hidden|^hid$| hid$| hid |^hid |banner|combx|comment|com-|contact|foot|footer|footnote|gdpr|masthead|media|meta|outbrain|promo|related|scroll|share|shoutbox|sidebar|skyscraper|sponsor|shopping|tags|tool|widget`)
+	whitespace         = regexp.MustCompile(`\s+`)
+)
+
+// Nodes that never carry article content.
+var removedTags = map[atom.Atom]bool{
+	atom.Script:   true,
+	atom.Style:    true,
+	atom.Noscript: true,
+	atom.Iframe:   true,
+	atom.Form:     true,
+	atom.Nav:      true,
+	atom.Footer:   true,
+	atom.Aside:    true,
+	atom.Svg:      true,
+	atom.Button:   true,
+	atom.Input:    true,
+	atom.Select:   true,
+	atom.Textarea: true,
+	atom.Object:   true,
+	atom.Embed:    true,
+	atom.Link:     true,
+	atom.Meta:     true,
+}
+
+var blockTags = map[atom.Atom]bool{
+	atom.Address: true, atom.Article: true, atom.Blockquote: true, atom.Div: true,
+	atom.Dl: true, atom.Figure: true, atom.H1: true, atom.H2: true, atom.H3: true,
+	atom.H4: true, atom.H5: true, atom.H6: true, atom.Li: true, atom.Ol: true,
+	atom.P: true, atom.Pre: true, atom.Section: true, atom.Table: true, atom.Ul: true,
+}
+
+// Extract finds the main content of an HTML page. pageURL is used to resolve
+// relative links and images and may be nil.
+func Extract(r io.Reader, pageURL *url.URL) (*Article, error) {
+	doc, err := html.Parse(r)
+	if err != nil {
+		return nil, err
+	}
+
+	article := &Article{}
+	readMetadata(doc, article, pageURL)
+
+	prune(doc)
+
+	top, scores := topCandidate(doc)
+	if top == nil {
+		return nil, ErrNoContent
+	}
+
+	nodes := contentNodes(top, scores)
+	article.HTML = sanitize(nodes, pageURL)
+	article.Text = plainText(nodes)
+	if article.Text == "" {
+		return nil, ErrNoContent
+	}
+
+	article.WordCount = len(strings.Fields(article.Text))
+	article.ReadingTime = int(math.Ceil(float64(article.WordCount) / WordsPerMinute))
+
+	if article.LeadImage == "" {
+		article.LeadImage = firstImage(nodes, pageURL)
+	}
+
+	return article, nil
+}
+
+// readMetadata collects title, author and lead image before pruning removes
+// the elements they usually live in.
+func readMetadata(doc *html.Node, article *Article, pageURL *url.URL) {
+	var title string
+
+	walk(doc, func(n *html.Node) bool {
+		if n.Type != html.ElementNode {
+			return true
+		}
+
+		switch n.DataAtom {
+		case atom.Title:
+			if title == "" {
+				title = innerText(n)
+			}
+		case atom.Meta:
+			key := strings.ToLower(attr(n, "property"))
+			if key == "" {
+				key = strings.ToLower(attr(n, "name"))
+			}
+			content := strings.TrimSpace(attr(n, "content"))
+			if content == "" {
+				return true
+			}
+
+			switch key {
+			case "og:title":
+				article.Title = content
+			case "og:image", "og:image:url", "twitter:image", "twitter:image:src":
+				if article.LeadImage == "" {
+					article.LeadImage = resolveURL(pageURL, content)
+				}
+			case "author", "byline", "dc.creator", "article:author", "parsely-author":
+				// article:author is frequently a profile URL rather than a name
+				if article.Author == "" && !strings.HasPrefix(content, "http") {
+					article.Author = content
+				}
+			}
+		case atom.Link:
+			if attr(n, "rel") == "image_src" && article.LeadImage == "" {
+				article.LeadImage = resolveURL(pageURL, attr(n, "href"))
+			}
+		default:
+			if article.Author == "" && isByline(n) {
+				article.Author = innerText(n)
+			}
+		}
+		return true
+	})
+
+	if article.Title == "" {
+		article.Title = title
+	}
+}
+
+func isByline(n *html.Node) bool {
+	if attr(n, "rel") != "author" && attr(n, "itemprop") != "author" {
+		match := attr(n, "class") + " " + attr(n, "id")
+		if !strings.Contains(strings.ToLower(match), "byline") {
+			return false
+		}
+	}
+
+	text := innerText(n)
+	return text != "" && len(text) < 100
+}
+
+// prune drops elements that cannot be content and those whose class or id
+// marks them as page furniture.
+func prune(doc *html.Node) {
+	var remove []*html.Node
+
+	walk(doc, func(n *html.Node) bool {
+		if n.Type == html.CommentNode {
+			remove = append(remove, n)
+			return false
+		}
+		if n.Type != html.ElementNode {
+			return true
+		}
+
+		if removedTags[n.DataAtom] {
+			remove = append(remove, n)
+			return false
+		}
+
+		switch n.DataAtom {
+		case atom.Html, atom.Body, atom.Article, atom.Main:
+			return true
+		}
+
+		match := attr(n, "class") + " " + attr(n, "id")
+		if unlikelyCandidates.MatchString(match) && !maybeCandidate.MatchString(match) {
+			remove = append(remove, n)
+			return false
+		}
+		if attr(n, "hidden") != "" || strings.Contains(strings.ReplaceAll(attr(n, "style"), " ", ""), "display:none") {
+			remove = append(remove, n)
+			return false
+		}
+		return true
+	})
+
+	for _, n := range remove {
+		if n.Parent != nil {
+			n.Parent.RemoveChild(n)
+		}
+	}
+}
+
+// topCandidate scores every paragraph-like node, credits its parent and
+// grandparent, and returns the ancestor with the best link-density-adjusted
+// score along with the scores of all candidates.
+func topCandidate(doc *html.Node) (*html.Node, map[*html.Node]float64) {
+	scores := map[*html.Node]float64{}
+	var candidates []*html.Node
+
+	walk(doc, func(n *html.Node) bool {
+		if n.Type != html.ElementNode || !isParagraph(n) {
+			return true
+		}
+
+		text := innerText(n)
+		if len(text) < 25 {
+			return true
+		}
+
+		score := 1 + float64(strings.Count(text, ",")) + math.Min(float64(len(text))/100, 3)
+
+		for level, ancestor := 0, n.Parent; level < 2 && ancestor != nil; level, ancestor = level+1, ancestor.Parent {
+			if ancestor.Type != html.ElementNode {
+				break
+			}
+			if _, ok := scores[ancestor]; !ok {
+				scores[ancestor] = initialScore(ancestor)
+				candidates = append(candidates, ancestor)
+			}
+			if level == 0 {
+				scores[ancestor] += score
+			} else {
+				scores[ancestor] += score / 2
+			}
+		}
+		return true
+	})
+
+	var (
+		top      *html.Node
+		topScore float64
+	)
+	for _, candidate := range candidates {
+		score := scores[candidate] * (1 - linkDensity(candidate))
+		scores[candidate] = score
+		if top == nil || score > topScore {
+			top, topScore = candidate, score
+		}
+	}
+
+	return top, scores
+}
+
+func isParagraph(n *html.Node) bool {
+	switch n.DataAtom {
+	case atom.P, atom.Pre, atom.Td:
+		return true
+	case atom.Div:
+		// A div holding only inline content reads as a paragraph
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type == html.ElementNode && blockTags[c.DataAtom] {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+func initialScore(n *html.Node) float64 {
+	var score float64
+	switch n.DataAtom {
+	case atom.Article:
+		score = 10
+	case atom.Div:
+		score = 5
+	case atom.Section, atom.Pre, atom.Td, atom.Blockquote:
+		score = 3
+	case atom.Address, atom.Ol, atom.Ul, atom.Dl, atom.Dd, atom.Dt, atom.Li, atom.Form:
+		score = -3
+	case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6, atom.Th:
+		score = -5
+	}
+	return score + classWeight(n)
+}
+
+func classWeight(n *html.Node) float64 {
+	var weight float64
+	for _, value := range []string{attr(n, "class"), attr(n, "id")} {
+		if value == "" {
+			continue
+		}
+		if negativeWeight.MatchString(value) {
+			weight -= 25
+		}
+		if positiveWeight.MatchString(value) {
+			weight += 25
+		}
+	}
+	return weight
+}
+
+func linkDensity(n *html.Node) float64 {
+	textLength := len(innerText(n))
+	if textLength == 0 {
+		return 0
+	}
+
+	var linkLength int
+	walk(n, func(c *html.Node) bool {
+		if c.Type == html.ElementNode && c.DataAtom == atom.A {
+			linkLength += len(innerText(c))
+			return false
+		}
+		return true
+	})
+	return float64(linkLength) / float64(textLength)
+}
+
+// contentNodes returns the top candidate together with any siblings that
+// look like they belong to the same article, in document order.
+func contentNodes(top *html.Node, scores map[*html.Node]float64) []*html.Node {
+	if top.Parent == nil {
+		return []*html.Node{top}
+	}
+
+	threshold := math.Max(10, scores[top]*0.2)
+
+	var nodes []*html.Node
+	for sibling := top.Parent.FirstChild; sibling != nil; sibling = sibling.NextSibling {
+		if sibling == top {
+			nodes = append(nodes, sibling)
+			continue
+		}
+		if sibling.Type != html.ElementNode {
+			continue
+		}
+
+		if score, ok := scores[sibling]; ok && score >= threshold {
+			nodes = append(nodes, sibling)
+			continue
+		}
+
+		if sibling.DataAtom == atom.P {
+			text := innerText(sibling)
+			density := linkDensity(sibling)
+			if (len(text) > 80 && density < 0.25) ||
+				(len(text) > 0 && density == 0 && strings.HasSuffix(text, ".")) {
+				nodes = append(nodes, sibling)
+			}
+		}
+	}
+
+	return nodes
+}
+
+func firstImage(nodes []*html.Node, pageURL *url.URL) string {
+	for _, n := range nodes {
+		var found string
+		walk(n, func(c *html.Node) bool {
+			if found != "" {
+				return false
+			}
+			if c.Type == html.ElementNode && c.DataAtom == atom.Img {
+				found = resolveURL(pageURL, imageSource(c))
+			}
+			return true
+		})
+		if found != "" {
+			return found
+		}
+	}
+	return ""
+}
+
+// walk visits n and its descendants depth-first; fn returning false skips
+// the node's children.
+func walk(n *html.Node, fn func(*html.Node) bool) {
+	if !fn(n) {
+		return
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		walk(c, fn)
+	}
+}
+
+func innerText(n *html.Node) string {
+	var b strings.Builder
+	walk(n, func(c *html.Node) bool {
+		if c.Type == html.TextNode {
+			b.WriteString(c.Data)
+			b.WriteByte(' ')
+		}
+		return true
+	})
+	return strings.TrimSpace(whitespace.ReplaceAllString(b.String(), " "))
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+// imageSource prefers the real source of lazy-loaded images.
+func imageSource(n *html.Node) string {
+	for _, key := range []string{"data-src", "data-original", "src"} {
+		if value := strings.TrimSpace(attr(n, key)); value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// resolveURL makes ref absolute against base and drops anything that is not
+// http(s), which also rules out javascript: and data: URLs.
+func resolveURL(base *url.URL, ref string) string {
+	ref = strings.TrimSpace(ref)
+	if ref == "" {
+		return ""
+	}
+
+	u, err := url.Parse(ref)
+	if err != nil {
+		return ""
+	}
+	if base != nil {
+		u = base.ResolveReference(u)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return ""
+	}
+	return u.String()
+}
@@ -0,0 +1,119 @@
+package extract
+
+import (
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// allowedTags are kept in the sanitized HTML; any other element is unwrapped
+// so that its text survives without its markup.
+var allowedTags = map[atom.Atom]bool{
+	atom.P: true, atom.Br: true, atom.H2: true, atom.H3: true, atom.H4: true,
+	atom.H5: true, atom.H6: true, atom.Ul: true, atom.Ol: true, atom.Li: true,
+	atom.Blockquote: true, atom.Pre: true, atom.Code: true, atom.Em: true,
+	atom.Strong: true, atom.B: true, atom.I: true, atom.A: true, atom.Img: true,
+	atom.Figure: true, atom.Figcaption: true, atom.Table: true, atom.Thead: true,
+	atom.Tbody: true, atom.Tr: true, atom.Th: true, atom.Td: true,
+}
+
+// sanitize renders nodes keeping only allowlisted tags and the attributes
+// needed to display them, with URLs made absolute.
+func sanitize(nodes []*html.Node, pageURL *url.URL) string {
+	var b strings.Builder
+	for _, n := range nodes {
+		writeSanitized(&b, n, pageURL)
+	}
+	return strings.TrimSpace(b.String())
+}
+
+func writeSanitized(b *strings.Builder, n *html.Node, pageURL *url.URL) {
+	switch n.Type {
+	case html.TextNode:
+		b.WriteString(html.EscapeString(n.Data))
+		return
+	case html.ElementNode:
+	default:
+		return
+	}
+
+	if !allowedTags[n.DataAtom] {
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			writeSanitized(b, c, pageURL)
+		}
+		return
+	}
+
+	switch n.DataAtom {
+	case atom.Br:
+		b.WriteString("<br>")
+		return
+	case atom.Img:
+		src := resolveURL(pageURL, imageSource(n))
+		if src == "" {
+			return
+		}
+		b.WriteString(`<img src="` + html.EscapeString(src) + `"`)
+		if alt := attr(n, "alt"); alt != "" {
+			b.WriteString(` alt="` + html.EscapeString(alt) + `"`)
+		}
+		b.WriteString(">")
+		return
+	}
+
+	tag := n.DataAtom.String()
+	b.WriteString("<" + tag)
+	if n.DataAtom == atom.A {
+		if href := resolveURL(pageURL, attr(n, "href")); href != "" {
+			b.WriteString(` href="` + html.EscapeString(href) + `" rel="nofollow noopener"`)
+		}
+	}
+	b.WriteString(">")
+
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		writeSanitized(b, c, pageURL)
+	}
+
+	b.WriteString("</" + tag + ">")
+}
+
+// plainText flattens nodes into text with a blank line between blocks.
+func plainText(nodes []*html.Node) string {
+	var b strings.Builder
+	for _, n := range nodes {
+		writeText(&b, n)
+	}
+
+	var paragraphs []string
+	for _, line := range strings.Split(b.String(), "\n") {
+		line = strings.TrimSpace(whitespace.ReplaceAllString(line, " "))
+		if line != "" {
+			paragraphs = append(paragraphs, line)
+		}
+	}
+	return strings.Join(paragraphs, "\n\n")
+}
+
+func writeText(b *strings.Builder, n *html.Node) {
+	switch n.Type {
+	case html.TextNode:
+		b.WriteString(strings.ReplaceAll(n.Data, "\n", " "))
+		return
+	case html.ElementNode:
+	default:
+		return
+	}
+
+	block := blockTags[n.DataAtom] || n.DataAtom == atom.Br || n.DataAtom == atom.Tr
+	if block {
+		b.WriteByte('\n')
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		writeText(b, c)
+	}
+	if block {
+		b.WriteByte('\n')
+	}
+}
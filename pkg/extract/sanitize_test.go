@@ -0,0 +1,105 @@
+package extract
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+var pageURL, _ = url.Parse("https://news.example.com/world/story.html")
+
+func TestResolveURL(t *testing.T) {
+	tests := []struct {
+		ref  string
+		want string
+	}{
+		{"https://cdn.example.com/a.jpg", "https://cdn.example.com/a.jpg"},
+		{"http://example.com/", "http://example.com/"},
+		{"/img/b.png", "https://news.example.com/img/b.png"},
+		{"c.png", "https://news.example.com/world/c.png"},
+		{"//cdn.example.com/d.png", "https://cdn.example.com/d.png"},
+		{"  /padded  ", "https://news.example.com/padded"},
+		{"", ""},
+		{"javascript:alert(1)", ""},
+		{"JavaScript:alert(1)", ""},
+		{" javascript:alert(1)", ""},
+		{"java\tscript:alert(1)", ""},
+		{"vbscript:msgbox(1)", ""},
+		{"data:text/html;base64,PHNjcmlwdD4=", ""},
+		{"data:image/png;base64,iVBORw0KGgo=", ""},
+		{"file:///etc/passwd", ""},
+		{"mailto:editor@example.com", ""},
+		{"ftp://example.com/file", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ref, func(t *testing.T) {
+			if got := resolveURL(pageURL, tt.ref); got != tt.want {
+				t.Errorf("resolveURL(%q) = %q, want %q", tt.ref, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSanitize(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{
+			"keeps http links",
+			`<p><a href="/next" title="x" onclick="steal()">Next</a></p>`,
+			`<p><a href="https://news.example.com/next" rel="nofollow noopener">Next</a></p>`,
+		},
+		{
+			"drops javascript hrefs",
+			`<p><a href="javascript:alert(1)">Click</a></p>`,
+			`<p><a>Click</a></p>`,
+		},
+		{
+			"drops entity-encoded javascript hrefs",
+			`<a href="&#106;avascript:alert(1)">Click</a>`,
+			`<a>Click</a>`,
+		},
+		{
+			"drops images without an http source",
+			`<p><img src="data:image/svg+xml,<svg onload=alert(1)>">text</p>`,
+			`<p>text</p>`,
+		},
+		{
+			"prefers lazy-loaded sources",
+			`<img src="placeholder.gif" data-src="/real.jpg" alt="a &quot;b&quot;" onerror="x()">`,
+			`<img src="https://news.example.com/real.jpg" alt="a &#34;b&#34;">`,
+		},
+		{
+			"unwraps unknown tags and drops their attributes",
+			`<div style="color:red"><span onmouseover="x()">Hi</span> <u>there</u></div>`,
+			`Hi there`,
+		},
+		{
+			"escapes text",
+			`<p>&lt;script&gt;alert(1)&lt;/script&gt;</p>`,
+			`<p>&lt;script&gt;alert(1)&lt;/script&gt;</p>`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nodes, err := html.ParseFragment(strings.NewReader(tt.in), &html.Node{
+				Type:     html.ElementNode,
+				Data:     "body",
+				DataAtom: atom.Body,
+			})
+			if err != nil {
+				t.Fatalf("parse: %v", err)
+			}
+			if got := sanitize(nodes, pageURL); got != tt.want {
+				t.Errorf("sanitize() = %s\nwant %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,137 @@
+package feed
+
+import (
+	"strconv"
+	"strings"
+)
+
+type atomFeed struct {
+	Title           atomText    `xml:"title"`
+	Subtitle        atomText    `xml:"subtitle"`
+	Links           []atomLink  `xml:"link"`
+	Lang            string      `xml:"http://www.w3.org/XML/1998/namespace lang,attr"`
+	UpdatePeriod    string      `xml:"http://purl.org/rss/1.0/modules/syndication/ updatePeriod"`
+	UpdateFrequency string      `xml:"http://purl.org/rss/1.0/modules/syndication/ updateFrequency"`
+	Entries         []atomEntry `xml:"entry"`
+}
+
+type atomEntry struct {
+	ID         string         `xml:"id"`
+	Title      atomText       `xml:"title"`
+	Links      []atomLink     `xml:"link"`
+	Summary    atomText       `xml:"summary"`
+	Content    atomText       `xml:"content"`
+	Authors    []atomPerson   `xml:"author"`
+	Categories []atomCategory `xml:"category"`
+	Published  string         `xml:"published"`
+	Updated    string         `xml:"updated"`
+}
+
+// atomText is an Atom text construct; xhtml content is kept as markup,
+// text and html content are taken as character data.
+type atomText struct {
+	Type  string `xml:"type,attr"`
+	Text  string `xml:",chardata"`
+	Inner string `xml:",innerxml"`
+}
+
+func (t atomText) String() string {
+	if t.Type == "xhtml" {
+		return strings.TrimSpace(t.Inner)
+	}
+	return strings.TrimSpace(t.Text)
+}
+
+// Length is kept as text: publishers send empty or non-numeric values, which
+// would otherwise fail the whole feed.
+type atomLink struct {
+	Href   string `xml:"href,attr"`
+	Rel    string `xml:"rel,attr"`
+	Type   string `xml:"type,attr"`
+	Length string `xml:"length,attr"`
+}
+
+type atomPerson struct {
+	Name  string `xml:"name"`
+	Email string `xml:"email"`
+}
+
+type atomCategory struct {
+	Term  string `xml:"term,attr"`
+	Label string `xml:"label,attr"`
+}
+
+func parseAtom(data []byte) (*Feed, error) {
+	var doc atomFeed
+	if err := decodeXML(data, &doc); err != nil {
+		return nil, err
+	}
+
+	f := &Feed{
+		Title:       doc.Title.String(),
+		Link:        alternateLink(doc.Links),
+		Description: doc.Subtitle.String(),
+		Language:    strings.TrimSpace(doc.Lang),
+		TTL:         syndicationInterval(doc.UpdatePeriod, doc.UpdateFrequency),
+	}
+
+	for _, entry := range doc.Entries {
+		item := Item{
+			GUID:      strings.TrimSpace(entry.ID),
+			Title:     entry.Title.String(),
+			Link:      alternateLink(entry.Links),
+			Summary:   entry.Summary.String(),
+			Content:   entry.Content.String(),
+			Published: parseDate(entry.Published),
+			Updated:   parseDate(entry.Updated),
+		}
+		if item.Published.IsZero() {
+			item.Published = item.Updated
+		}
+
+		for _, author := range entry.Authors {
+			name := author.Name
+			if strings.TrimSpace(name) == "" {
+				name = author.Email
+			}
+			item.Authors = appendUnique(item.Authors, name)
+		}
+		for _, category := range entry.Categories {
+			label := category.Label
+			if strings.TrimSpace(label) == "" {
+				label = category.Term
+			}
+			item.Categories = appendUnique(item.Categories, label)
+		}
+		for _, link := range entry.Links {
+			if link.Rel == "enclosure" && link.Href != "" {
+				length, _ := strconv.ParseInt(strings.TrimSpace(link.Length), 10, 64)
+				item.Enclosures = append(item.Enclosures, Enclosure{
+					URL:    strings.TrimSpace(link.Href),
+					Type:   strings.TrimSpace(link.Type),
+					Length: length,
+				})
+			}
+		}
+
+		f.Items = append(f.Items, item)
+	}
+
+	return f, nil
+}
+
+// alternateLink returns the rel="alternate" link, which is also the default
+// when rel is omitted, falling back to the first link with an href.
+func alternateLink(links []atomLink) string {
+	for _, link := range links {
+		if (link.Rel == "" || link.Rel == "alternate") && link.Href != "" {
+			return strings.TrimSpace(link.Href)
+		}
+	}
+	for _, link := range links {
+		if link.Rel != "enclosure" && link.Href != "" {
+			return strings.TrimSpace(link.Href)
+		}
+	}
+	return ""
+}
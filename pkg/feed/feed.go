@@ -0,0 +1,228 @@
+package feed
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html/charset"
+)
+
+type Format string
+
+const (
+	FormatUnknown Format = "unknown"
+	FormatRSS     Format = "rss"
+	FormatAtom    Format = "atom"
+	FormatRDF     Format = "rdf"
+	FormatJSON    Format = "json"
+)
+
+var ErrUnknownFormat = errors.New("feed: unrecognized feed format")
+
+// Feed is the format-independent representation of a parsed feed.
+type Feed struct {
+	Format      Format
+	Title       string
+	Link        string
+	Description string
+	Language    string
+	// TTL is the publisher's hint for how long the feed may be cached,
+	// taken from <ttl> or sy:updatePeriod/sy:updateFrequency. Zero if absent.
+	TTL   time.Duration
+	Items []Item
+}
+
+// Item is a single normalized feed entry.
+type Item struct {
+	GUID       string
+	Title      string
+	Link       string
+	Summary    string
+	Content    string
+	Authors    []string
+	Categories []string
+	Enclosures []Enclosure
+	Published  time.Time
+	Updated    time.Time
+}
+
+type Enclosure struct {
+	URL    string
+	Type   string
+	Length int64
+}
+
+// Parse sniffs the format of data and decodes it into a Feed.
+func Parse(data []byte) (*Feed, error) {
+	format := Detect(data)
+
+	var (
+		f   *Feed
+		err error
+	)
+	switch format {
+	case FormatRSS:
+		f, err = parseRSS(data)
+	case FormatAtom:
+		f, err = parseAtom(data)
+	case FormatRDF:
+		f, err = parseRDF(data)
+	case FormatJSON:
+		f, err = parseJSON(data)
+	default:
+		return nil, ErrUnknownFormat
+	}
+	if err != nil {
+		return nil, fmt.Errorf("feed: parse %s: %w", format, err)
+	}
+
+	f.Format = format
+	return f, nil
+}
+
+// Detect inspects the document root to decide which format data is in.
+func Detect(data []byte) Format {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 {
+		return FormatUnknown
+	}
+
+	if data[0] == '{' {
+		if bytes.Contains(data, []byte("jsonfeed.org/version")) {
+			return FormatJSON
+		}
+		return FormatUnknown
+	}
+
+	decoder := newXMLDecoder(bytes.NewReader(data))
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return FormatUnknown
+		}
+
+		start, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+
+		switch strings.ToLower(start.Name.Local) {
+		case "rss":
+			return FormatRSS
+		case "feed":
+			return FormatAtom
+		case "rdf":
+			return FormatRDF
+		default:
+			return FormatUnknown
+		}
+	}
+}
+
+func newXMLDecoder(r io.Reader) *xml.Decoder {
+	decoder := xml.NewDecoder(r)
+	decoder.CharsetReader = charset.NewReaderLabel
+	decoder.Strict = false
+	decoder.Entity = xml.HTMLEntity
+	return decoder
+}
+
+func decodeXML(data []byte, v interface{}) error {
+	return newXMLDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+var dateLayouts = []string{
+	time.RFC1123Z,
+	time.RFC1123,
+	time.RFC3339,
+	time.RFC3339Nano,
+	time.RFC822Z,
+	time.RFC822,
+	time.RFC850,
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+	"Mon, 2 Jan 2006 15:04:05 MST",
+	"Mon, 02 Jan 2006 15:04 -0700",
+	"2 Jan 2006 15:04:05 -0700",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+}
+
+// parseDate tries the date layouts commonly found in the wild and returns
+// the zero time if none of them match.
+func parseDate(value string) time.Time {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}
+	}
+
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// syndicationInterval converts the RSS syndication module's updatePeriod and
+// updateFrequency into the interval between updates.
+func syndicationInterval(period, frequency string) time.Duration {
+	var base time.Duration
+	switch strings.ToLower(strings.TrimSpace(period)) {
+	case "hourly":
+		base = time.Hour
+	case "daily":
+		base = 24 * time.Hour
+	case "weekly":
+		base = 7 * 24 * time.Hour
+	case "monthly":
+		base = 30 * 24 * time.Hour
+	case "yearly":
+		base = 365 * 24 * time.Hour
+	default:
+		return 0
+	}
+
+	freq, err := strconv.Atoi(strings.TrimSpace(frequency))
+	if err != nil || freq < 1 {
+		freq = 1
+	}
+	return base / time.Duration(freq)
+}
+
+// ttlMinutes parses the RSS <ttl> element, which is a number of minutes.
+func ttlMinutes(value string) time.Duration {
+	minutes, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil || minutes < 1 {
+		return 0
+	}
+	return time.Duration(minutes) * time.Minute
+}
+
+func appendUnique(list []string, values ...string) []string {
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+
+		exists := false
+		for _, existing := range list {
+			if existing == value {
+				exists = true
+				break
+			}
+		}
+		if !exists {
+			list = append(list, value)
+		}
+	}
+	return list
+}
@@ -0,0 +1,338 @@
+package feed
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+const rssFixture = `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0" xmlns:dc="http://purl.org/dc/elements/1.1/"
+  xmlns:content="http://purl.org/rss/1.0/modules/content/"
+  xmlns:atom="http://www.w3.org/2005/Atom">
+<channel>
+  <title> Example News </title>
+  <atom:link href="https://example.com/rss" rel="self"/>
+  <link>https://example.com/</link>
+  <description>All the news</description>
+  <language>vi</language>
+  <ttl>15</ttl>
+  <item>
+    <guid>item-1</guid>
+    <title>First &amp; foremost</title>
+    <link>https://example.com/1</link>
+    <description>Summary</description>
+    <content:encoded><![CDATA[<p>Body</p>]]></content:encoded>
+    <author>alice@example.com</author>
+    <dc:creator>Bob</dc:creator>
+    <dc:creator>Bob</dc:creator>
+    <category>World</category>
+    <enclosure url="https://example.com/1.mp3" type="audio/mpeg" length="1234"/>
+    <pubDate>Mon, 02 Jan 2006 15:04:05 +0700</pubDate>
+  </item>
+  <item>
+    <title>Dated by Dublin Core</title>
+    <dc:date>2006-01-02T08:04:05Z</dc:date>
+  </item>
+</channel>
+</rss>`
+
+const atomFixture = `<?xml version="1.0" encoding="utf-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom" xml:lang="en">
+  <title>Atom News</title>
+  <subtitle type="html">Sub</subtitle>
+  <link rel="self" href="https://example.com/atom"/>
+  <link href="https://example.com/"/>
+  <entry>
+    <id>urn:uuid:1</id>
+    <title type="text">Entry</title>
+    <link rel="enclosure" href="https://example.com/a.mp3" type="audio/mpeg" length="10"/>
+    <link rel="alternate" href="https://example.com/entry"/>
+    <summary>Short</summary>
+    <content type="xhtml"><div xmlns="http://www.w3.org/1999/xhtml"><p>Hi</p></div></content>
+    <author><name>Alice</name></author>
+    <author><email>bob@example.com</email></author>
+    <category term="tech" label="Technology"/>
+    <category term="go"/>
+    <updated>2006-01-02T15:04:05+07:00</updated>
+  </entry>
+</feed>`
+
+const rdfFixture = `<?xml version="1.0"?>
+<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#"
+  xmlns="http://purl.org/rss/1.0/"
+  xmlns:dc="http://purl.org/dc/elements/1.1/"
+  xmlns:sy="http://purl.org/rss/1.0/modules/syndication/">
+  <channel rdf:about="https://example.com/">
+    <title>RDF News</title>
+    <link>https://example.com/</link>
+    <dc:language>fr</dc:language>
+    <sy:updatePeriod>hourly</sy:updatePeriod>
+    <sy:updateFrequency>4</sy:updateFrequency>
+  </channel>
+  <item rdf:about="https://example.com/r1">
+    <title>RDF item</title>
+    <dc:creator>Carol</dc:creator>
+    <dc:subject>Science</dc:subject>
+    <dc:date>2006-01-02T08:04:05Z</dc:date>
+  </item>
+</rdf:RDF>`
+
+const jsonFixture = `{
+  "version": "https://jsonfeed.org/version/1.1",
+  "title": "JSON News",
+  "home_page_url": "https://example.com/",
+  "items": [
+    {
+      "id": 42,
+      "external_url": "https://elsewhere.example/42",
+      "title": "JSON item",
+      "content_text": "Plain",
+      "date_modified": "2006-01-02T08:04:05Z",
+      "author": {"name": "Dave"},
+      "authors": [{"name": "Dave"}, {"name": "Eve"}],
+      "tags": ["a", "b"],
+      "attachments": [{"url": "https://example.com/42.png", "mime_type": "image/png", "size_in_bytes": 99}]
+    }
+  ]
+}`
+
+var reference = time.Date(2006, 1, 2, 8, 4, 5, 0, time.UTC)
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want Format
+	}{
+		{"rss", rssFixture, FormatRSS},
+		{"atom", atomFixture, FormatAtom},
+		{"rdf", rdfFixture, FormatRDF},
+		{"json feed", jsonFixture, FormatJSON},
+		{"byte order mark", "\xef\xbb\xbf" + rssFixture, FormatRSS},
+		{"plain json", `{"items": []}`, FormatUnknown},
+		{"html", "<html><body></body></html>", FormatUnknown},
+		{"empty", "   ", FormatUnknown},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Detect([]byte(tt.data)); got != tt.want {
+				t.Errorf("Detect() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name  string
+		data  string
+		check func(t *testing.T, f *Feed)
+	}{
+		{"rss", rssFixture, func(t *testing.T, f *Feed) {
+			expect(t, "title", f.Title, "Example News")
+			expect(t, "link", f.Link, "https://example.com/")
+			expect(t, "language", f.Language, "vi")
+			if f.TTL != 15*time.Minute {
+				t.Errorf("TTL = %v, want 15m", f.TTL)
+			}
+			if len(f.Items) != 2 {
+				t.Fatalf("got %d items, want 2", len(f.Items))
+			}
+
+			item := f.Items[0]
+			expect(t, "guid", item.GUID, "item-1")
+			expect(t, "item title", item.Title, "First & foremost")
+			expect(t, "content", item.Content, "<p>Body</p>")
+			expectList(t, "authors", item.Authors, "alice@example.com", "Bob")
+			expectList(t, "categories", item.Categories, "World")
+			if len(item.Enclosures) != 1 || item.Enclosures[0].Length != 1234 {
+				t.Errorf("enclosures = %+v", item.Enclosures)
+			}
+			expectTime(t, item.Published, reference)
+			expectTime(t, f.Items[1].Published, reference)
+		}},
+		{"atom", atomFixture, func(t *testing.T, f *Feed) {
+			expect(t, "title", f.Title, "Atom News")
+			expect(t, "link", f.Link, "https://example.com/")
+			expect(t, "language", f.Language, "en")
+			if len(f.Items) != 1 {
+				t.Fatalf("got %d items, want 1", len(f.Items))
+			}
+
+			item := f.Items[0]
+			expect(t, "link", item.Link, "https://example.com/entry")
+			expect(t, "content", item.Content, `<div xmlns="http://www.w3.org/1999/xhtml"><p>Hi</p></div>`)
+			expectList(t, "authors", item.Authors, "Alice", "bob@example.com")
+			expectList(t, "categories", item.Categories, "Technology", "go")
+			if len(item.Enclosures) != 1 || item.Enclosures[0].URL != "https://example.com/a.mp3" {
+				t.Errorf("enclosures = %+v", item.Enclosures)
+			}
+			// Published falls back to updated
+			expectTime(t, item.Published, reference)
+		}},
+		{"rdf", rdfFixture, func(t *testing.T, f *Feed) {
+			expect(t, "language", f.Language, "fr")
+			if f.TTL != 15*time.Minute {
+				t.Errorf("TTL = %v, want 15m", f.TTL)
+			}
+			if len(f.Items) != 1 {
+				t.Fatalf("got %d items, want 1", len(f.Items))
+			}
+
+			item := f.Items[0]
+			expect(t, "guid", item.GUID, "https://example.com/r1")
+			expect(t, "link", item.Link, "https://example.com/r1")
+			expectList(t, "authors", item.Authors, "Carol")
+			expectList(t, "categories", item.Categories, "Science")
+			expectTime(t, item.Published, reference)
+		}},
+		{"json feed", jsonFixture, func(t *testing.T, f *Feed) {
+			expect(t, "link", f.Link, "https://example.com/")
+			if len(f.Items) != 1 {
+				t.Fatalf("got %d items, want 1", len(f.Items))
+			}
+
+			item := f.Items[0]
+			expect(t, "guid", item.GUID, "42")
+			expect(t, "link", item.Link, "https://elsewhere.example/42")
+			expect(t, "content", item.Content, "Plain")
+			expectList(t, "authors", item.Authors, "Dave", "Eve")
+			expectList(t, "categories", item.Categories, "a", "b")
+			if len(item.Enclosures) != 1 || item.Enclosures[0].Length != 99 {
+				t.Errorf("enclosures = %+v", item.Enclosures)
+			}
+			expectTime(t, item.Published, reference)
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := Parse([]byte(tt.data))
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if f.Format != Detect([]byte(tt.data)) {
+				t.Errorf("Format = %q", f.Format)
+			}
+			tt.check(t, f)
+		})
+	}
+}
+
+func TestParseAtomBadEnclosureLength(t *testing.T) {
+	data := `<?xml version="1.0" encoding="utf-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom">
+  <title>Atom News</title>
+  <entry>
+    <id>urn:uuid:1</id>
+    <link rel="enclosure" href="https://example.com/a.mp3" length=""/>
+  </entry>
+  <entry>
+    <id>urn:uuid:2</id>
+    <link rel="enclosure" href="https://example.com/b.mp3" length="about 3 MB"/>
+  </entry>
+  <entry>
+    <id>urn:uuid:3</id>
+    <link rel="enclosure" href="https://example.com/c.mp3" length=" 42 "/>
+  </entry>
+</feed>`
+
+	f, err := Parse([]byte(data))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if len(f.Items) != 3 {
+		t.Fatalf("got %d items, want 3", len(f.Items))
+	}
+	for i, want := range []int64{0, 0, 42} {
+		enclosures := f.Items[i].Enclosures
+		if len(enclosures) != 1 || enclosures[0].Length != want {
+			t.Errorf("item %d enclosures = %+v, want length %d", i, enclosures, want)
+		}
+	}
+}
+
+func TestParseUnknownFormat(t *testing.T) {
+	if _, err := Parse([]byte("<html></html>")); !errors.Is(err, ErrUnknownFormat) {
+		t.Errorf("Parse() error = %v, want ErrUnknownFormat", err)
+	}
+}
+
+func TestParseDate(t *testing.T) {
+	tests := []struct {
+		value string
+		want  time.Time
+	}{
+		{"Mon, 02 Jan 2006 15:04:05 +0700", reference},
+		{"Mon, 2 Jan 2006 15:04:05 +0700", reference},
+		{"Mon, 02 Jan 2006 08:04:05 GMT", reference},
+		{"2006-01-02T15:04:05+07:00", reference},
+		{"2006-01-02T08:04:05.000Z", reference},
+		{"02 Jan 06 15:04 +0700", reference.Add(-5 * time.Second)},
+		{"Mon, 02 Jan 2006 15:04 +0700", reference.Add(-5 * time.Second)},
+		{"2 Jan 2006 15:04:05 +0700", reference},
+		{"2006-01-02 08:04:05", reference},
+		{"2006-01-02", time.Date(2006, 1, 2, 0, 0, 0, 0, time.UTC)},
+		{"  2006-01-02T08:04:05Z  ", reference},
+		{"", time.Time{}},
+		{"yesterday", time.Time{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			if got := parseDate(tt.value); !got.Equal(tt.want) {
+				t.Errorf("parseDate(%q) = %v, want %v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSyndicationInterval(t *testing.T) {
+	tests := []struct {
+		period, frequency string
+		want              time.Duration
+	}{
+		{"hourly", "", time.Hour},
+		{"hourly", "2", 30 * time.Minute},
+		{"Daily", "1", 24 * time.Hour},
+		{"weekly", "0", 7 * 24 * time.Hour},
+		{"", "4", 0},
+		{"fortnightly", "1", 0},
+	}
+
+	for _, tt := range tests {
+		if got := syndicationInterval(tt.period, tt.frequency); got != tt.want {
+			t.Errorf("syndicationInterval(%q, %q) = %v, want %v", tt.period, tt.frequency, got, tt.want)
+		}
+	}
+}
+
+func expect(t *testing.T, field, got, want string) {
+	t.Helper()
+	if got != want {
+		t.Errorf("%s = %q, want %q", field, got, want)
+	}
+}
+
+func expectList(t *testing.T, field string, got []string, want ...string) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Errorf("%s = %q, want %q", field, got, want)
+		return
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("%s = %q, want %q", field, got, want)
+			return
+		}
+	}
+}
+
+func expectTime(t *testing.T, got, want time.Time) {
+	t.Helper()
+	if !got.Equal(want) {
+		t.Errorf("time = %v, want %v", got, want)
+	}
+}
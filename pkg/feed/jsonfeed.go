@@ -0,0 +1,109 @@
+package feed
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// jsonFeed covers both JSON Feed 1.0 (single author) and 1.1 (authors list).
+type jsonFeed struct {
+	Version     string         `json:"version"`
+	Title       string         `json:"title"`
+	HomePageURL string         `json:"home_page_url"`
+	Description string         `json:"description"`
+	Language    string         `json:"language"`
+	Items       []jsonFeedItem `json:"items"`
+}
+
+type jsonFeedItem struct {
+	ID            json.RawMessage      `json:"id"`
+	URL           string               `json:"url"`
+	ExternalURL   string               `json:"external_url"`
+	Title         string               `json:"title"`
+	ContentHTML   string               `json:"content_html"`
+	ContentText   string               `json:"content_text"`
+	Summary       string               `json:"summary"`
+	DatePublished string               `json:"date_published"`
+	DateModified  string               `json:"date_modified"`
+	Author        *jsonFeedAuthor      `json:"author"`
+	Authors       []jsonFeedAuthor     `json:"authors"`
+	Tags          []string             `json:"tags"`
+	Attachments   []jsonFeedAttachment `json:"attachments"`
+}
+
+type jsonFeedAuthor struct {
+	Name string `json:"name"`
+}
+
+type jsonFeedAttachment struct {
+	URL         string `json:"url"`
+	MimeType    string `json:"mime_type"`
+	SizeInBytes int64  `json:"size_in_bytes"`
+}
+
+func parseJSON(data []byte) (*Feed, error) {
+	var doc jsonFeed
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+
+	f := &Feed{
+		Title:       strings.TrimSpace(doc.Title),
+		Link:        strings.TrimSpace(doc.HomePageURL),
+		Description: strings.TrimSpace(doc.Description),
+		Language:    strings.TrimSpace(doc.Language),
+	}
+
+	for _, it := range doc.Items {
+		link := it.URL
+		if link == "" {
+			link = it.ExternalURL
+		}
+
+		content := it.ContentHTML
+		if content == "" {
+			content = it.ContentText
+		}
+
+		item := Item{
+			GUID:       jsonFeedID(it.ID),
+			Title:      strings.TrimSpace(it.Title),
+			Link:       strings.TrimSpace(link),
+			Summary:    strings.TrimSpace(it.Summary),
+			Content:    strings.TrimSpace(content),
+			Categories: appendUnique(nil, it.Tags...),
+			Published:  parseDate(it.DatePublished),
+			Updated:    parseDate(it.DateModified),
+		}
+		if item.Published.IsZero() {
+			item.Published = item.Updated
+		}
+
+		if it.Author != nil {
+			item.Authors = appendUnique(item.Authors, it.Author.Name)
+		}
+		for _, author := range it.Authors {
+			item.Authors = appendUnique(item.Authors, author.Name)
+		}
+		for _, attachment := range it.Attachments {
+			item.Enclosures = append(item.Enclosures, Enclosure{
+				URL:    strings.TrimSpace(attachment.URL),
+				Type:   strings.TrimSpace(attachment.MimeType),
+				Length: attachment.SizeInBytes,
+			})
+		}
+
+		f.Items = append(f.Items, item)
+	}
+
+	return f, nil
+}
+
+// jsonFeedID accepts ids encoded as strings or, as some generators do, numbers.
+func jsonFeedID(raw json.RawMessage) string {
+	var id string
+	if err := json.Unmarshal(raw, &id); err == nil {
+		return strings.TrimSpace(id)
+	}
+	return strings.TrimSpace(string(raw))
+}
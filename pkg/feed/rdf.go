@@ -0,0 +1,66 @@
+package feed
+
+import (
+	"strings"
+)
+
+// RSS 1.0 keeps items as siblings of the channel under the rdf:RDF root.
+type rdfDocument struct {
+	Channel rdfChannel `xml:"channel"`
+	Items   []rdfItem  `xml:"item"`
+}
+
+type rdfChannel struct {
+	Title           string `xml:"title"`
+	Link            string `xml:"link"`
+	Description     string `xml:"description"`
+	Language        string `xml:"http://purl.org/dc/elements/1.1/ language"`
+	UpdatePeriod    string `xml:"http://purl.org/rss/1.0/modules/syndication/ updatePeriod"`
+	UpdateFrequency string `xml:"http://purl.org/rss/1.0/modules/syndication/ updateFrequency"`
+}
+
+type rdfItem struct {
+	About       string   `xml:"http://www.w3.org/1999/02/22-rdf-syntax-ns# about,attr"`
+	Title       string   `xml:"title"`
+	Link        string   `xml:"link"`
+	Description string   `xml:"description"`
+	Content     string   `xml:"http://purl.org/rss/1.0/modules/content/ encoded"`
+	Creators    []string `xml:"http://purl.org/dc/elements/1.1/ creator"`
+	Subjects    []string `xml:"http://purl.org/dc/elements/1.1/ subject"`
+	Date        string   `xml:"http://purl.org/dc/elements/1.1/ date"`
+}
+
+func parseRDF(data []byte) (*Feed, error) {
+	var doc rdfDocument
+	if err := decodeXML(data, &doc); err != nil {
+		return nil, err
+	}
+
+	f := &Feed{
+		Title:       strings.TrimSpace(doc.Channel.Title),
+		Link:        strings.TrimSpace(doc.Channel.Link),
+		Description: strings.TrimSpace(doc.Channel.Description),
+		Language:    strings.TrimSpace(doc.Channel.Language),
+		TTL:         syndicationInterval(doc.Channel.UpdatePeriod, doc.Channel.UpdateFrequency),
+	}
+
+	for _, it := range doc.Items {
+		link := strings.TrimSpace(it.Link)
+		if link == "" {
+			link = strings.TrimSpace(it.About)
+		}
+
+		f.Items = append(f.Items, Item{
+			GUID:       strings.TrimSpace(it.About),
+			Title:      strings.TrimSpace(it.Title),
+			Link:       link,
+			Summary:    strings.TrimSpace(it.Description),
+			Content:    strings.TrimSpace(it.Content),
+			Authors:    appendUnique(nil, it.Creators...),
+			Categories: appendUnique(nil, it.Subjects...),
+			Published:  parseDate(it.Date),
+		})
+	}
+
+	return f, nil
+}
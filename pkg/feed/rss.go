@@ -0,0 +1,102 @@
+package feed
+
+import (
+	"strconv"
+	"strings"
+)
+
+type rssDocument struct {
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title           string    `xml:"title"`
+	Links           []string  `xml:"link"`
+	Description     string    `xml:"description"`
+	Language        string    `xml:"language"`
+	TTL             string    `xml:"ttl"`
+	UpdatePeriod    string    `xml:"http://purl.org/rss/1.0/modules/syndication/ updatePeriod"`
+	UpdateFrequency string    `xml:"http://purl.org/rss/1.0/modules/syndication/ updateFrequency"`
+	Items           []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	GUID        string         `xml:"guid"`
+	Title       string         `xml:"title"`
+	Links       []string       `xml:"link"`
+	Description string         `xml:"description"`
+	Content     string         `xml:"http://purl.org/rss/1.0/modules/content/ encoded"`
+	Author      string         `xml:"author"`
+	Creators    []string       `xml:"http://purl.org/dc/elements/1.1/ creator"`
+	Categories  []string       `xml:"category"`
+	Enclosures  []rssEnclosure `xml:"enclosure"`
+	PubDate     string         `xml:"pubDate"`
+	Date        string         `xml:"http://purl.org/dc/elements/1.1/ date"`
+}
+
+type rssEnclosure struct {
+	URL    string `xml:"url,attr"`
+	Type   string `xml:"type,attr"`
+	Length string `xml:"length,attr"`
+}
+
+func parseRSS(data []byte) (*Feed, error) {
+	var doc rssDocument
+	if err := decodeXML(data, &doc); err != nil {
+		return nil, err
+	}
+
+	f := &Feed{
+		Title:       strings.TrimSpace(doc.Channel.Title),
+		Link:        firstNonEmpty(doc.Channel.Links...),
+		Description: strings.TrimSpace(doc.Channel.Description),
+		Language:    strings.TrimSpace(doc.Channel.Language),
+		TTL:         ttlMinutes(doc.Channel.TTL),
+	}
+	if f.TTL == 0 {
+		f.TTL = syndicationInterval(doc.Channel.UpdatePeriod, doc.Channel.UpdateFrequency)
+	}
+
+	for _, it := range doc.Channel.Items {
+		item := Item{
+			GUID:       strings.TrimSpace(it.GUID),
+			Title:      strings.TrimSpace(it.Title),
+			Link:       firstNonEmpty(it.Links...),
+			Summary:    strings.TrimSpace(it.Description),
+			Content:    strings.TrimSpace(it.Content),
+			Authors:    appendUnique(nil, append([]string{it.Author}, it.Creators...)...),
+			Categories: appendUnique(nil, it.Categories...),
+			Published:  parseDate(it.PubDate),
+		}
+		if item.Published.IsZero() {
+			item.Published = parseDate(it.Date)
+		}
+		for _, enc := range it.Enclosures {
+			item.Enclosures = append(item.Enclosures, rssEnclosureToEnclosure(enc))
+		}
+
+		f.Items = append(f.Items, item)
+	}
+
+	return f, nil
+}
+
+// firstNonEmpty skips the empty <atom:link/> elements many RSS feeds mix in
+// next to the plain <link>.
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value = strings.TrimSpace(value); value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+func rssEnclosureToEnclosure(enc rssEnclosure) Enclosure {
+	length, _ := strconv.ParseInt(strings.TrimSpace(enc.Length), 10, 64)
+	return Enclosure{
+		URL:    strings.TrimSpace(enc.URL),
+		Type:   strings.TrimSpace(enc.Type),
+		Length: length,
+	}
+}
@@ -0,0 +1,145 @@
+package jwks
+
+import (
+	"context"
+	"crypto"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var ErrKeyNotFound = errors.New("jwks: signing key not found")
+
+// minRefetchInterval bounds how often an unknown kid can trigger a fetch, so
+// tokens with made-up key IDs cannot hammer the auth service.
+const minRefetchInterval = 30 * time.Second
+
+// Client verifies tokens against a remote JWKS document. Keys are cached for
+// ttl and refetched early when a token names a kid the cache does not have,
+// which is how a freshly rotated key is picked up.
+type Client struct {
+	url        string
+	ttl        time.Duration
+	httpClient *http.Client
+
+	mu        sync.RWMutex
+	keys      map[string]cachedKey
+	fetchedAt time.Time
+	fetchMu   sync.Mutex
+}
+
+type cachedKey struct {
+	alg    string
+	public crypto.PublicKey
+}
+
+func NewClient(url string, ttl time.Duration) *Client {
+	return &Client{
+		url:        url,
+		ttl:        ttl,
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+		keys:       map[string]cachedKey{},
+	}
+}
+
+// Keyfunc resolves the verification key for token from its kid header. It
+// can be passed straight to jwt.Parse.
+func (c *Client) Keyfunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" {
+		return nil, fmt.Errorf("%w: token has no kid", ErrKeyNotFound)
+	}
+
+	key, ok, stale := c.lookup(kid)
+	if !ok || stale {
+		if err := c.refresh(!ok); err != nil && !ok {
+			return nil, err
+		}
+		key, ok, _ = c.lookup(kid)
+	}
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrKeyNotFound, kid)
+	}
+
+	if key.alg != "" && key.alg != token.Method.Alg() {
+		return nil, fmt.Errorf("jwks: key %s is for %s, token uses %s", kid, key.alg, token.Method.Alg())
+	}
+	return key.public, nil
+}
+
+func (c *Client) lookup(kid string) (cachedKey, bool, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	key, ok := c.keys[kid]
+	return key, ok, time.Since(c.fetchedAt) > c.ttl
+}
+
+// refresh refetches the key set. When missing is set the fetch was caused by
+// an unknown kid and is rate limited.
+func (c *Client) refresh(missing bool) error {
+	c.fetchMu.Lock()
+	defer c.fetchMu.Unlock()
+
+	c.mu.RLock()
+	since := time.Since(c.fetchedAt)
+	c.mu.RUnlock()
+	// Another caller refreshed while we waited for the lock
+	if since < c.ttl && (!missing || since < minRefetchInterval) {
+		return nil
+	}
+
+	keys, err := c.fetch()
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	c.keys = keys
+	c.fetchedAt = time.Now()
+	c.mu.Unlock()
+
+	return nil
+}
+
+func (c *Client) fetch() (map[string]cachedKey, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("jwks: fetch %s: %w", c.url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("jwks: fetch %s: unexpected status %d", c.url, resp.StatusCode)
+	}
+
+	var set Set
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return nil, fmt.Errorf("jwks: decode %s: %w", c.url, err)
+	}
+
+	keys := make(map[string]cachedKey, len(set.Keys))
+	for _, key := range set.Keys {
+		public, err := key.PublicKey()
+		if err != nil {
+			// Skip key types we cannot use rather than failing the set
+			continue
+		}
+		keys[key.Kid] = cachedKey{alg: key.Alg, public: public}
+	}
+
+	return keys, nil
+}
@@ -0,0 +1,87 @@
+package jwks
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+var ErrUnsupportedKey = errors.New("jwks: unsupported key type")
+
+// Key is a public JSON Web Key (RFC 7517) for RSA or Ed25519.
+type Key struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+
+	// RSA
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+
+	// OKP (Ed25519)
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+// Set is the document served at /.well-known/jwks.json.
+type Set struct {
+	Keys []Key `json:"keys"`
+}
+
+// NewKey describes a public key for publishing.
+func NewKey(kid, alg string, public crypto.PublicKey) (Key, error) {
+	key := Key{Kid: kid, Alg: alg, Use: "sig"}
+
+	switch pub := public.(type) {
+	case *rsa.PublicKey:
+		key.Kty = "RSA"
+		key.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+		key.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+	case ed25519.PublicKey:
+		key.Kty = "OKP"
+		key.Crv = "Ed25519"
+		key.X = base64.RawURLEncoding.EncodeToString(pub)
+	default:
+		return Key{}, fmt.Errorf("%w: %T", ErrUnsupportedKey, public)
+	}
+
+	return key, nil
+}
+
+// PublicKey decodes the key into the form jwt verification expects.
+func (k Key) PublicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("jwks: decode n: %w", err)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, fmt.Errorf("jwks: decode e: %w", err)
+		}
+		return &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}, nil
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, fmt.Errorf("%w: curve %s", ErrUnsupportedKey, k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, fmt.Errorf("jwks: decode x: %w", err)
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("jwks: bad Ed25519 key length %d", len(x))
+		}
+		return ed25519.PublicKey(x), nil
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedKey, k.Kty)
+	}
+}
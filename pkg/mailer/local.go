@@ -0,0 +1,47 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// LogMailer writes messages to the standard logger instead of sending them.
+// Useful in development, where the links in the body can be copied by hand.
+type LogMailer struct{}
+
+func NewLog() *LogMailer {
+	return &LogMailer{}
+}
+
+func (m *LogMailer) Send(ctx context.Context, msg Message) error {
+	log.Printf("Mail to %s: %s\n%s", msg.To, msg.Subject, msg.Body)
+	return nil
+}
+
+// FileMailer stores each message as an .eml file in a directory, for tests
+// and for inspecting mail with a regular client.
+type FileMailer struct {
+	from string
+	dir  string
+}
+
+func NewFile(from, dir string) (*FileMailer, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("mailer: create %s: %w", dir, err)
+	}
+	return &FileMailer{from: from, dir: dir}, nil
+}
+
+func (m *FileMailer) Send(ctx context.Context, msg Message) error {
+	body, err := compose(m.from, msg)
+	if err != nil {
+		return err
+	}
+
+	name := fmt.Sprintf("%s.eml", time.Now().Format("20060102T150405.000000000"))
+	return os.WriteFile(filepath.Join(m.dir, name), body, 0o644)
+}
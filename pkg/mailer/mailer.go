@@ -0,0 +1,83 @@
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"mime"
+	"net/mail"
+	"strings"
+	"time"
+)
+
+type Message struct {
+	To      string
+	Subject string
+	Body    string // plain text
+}
+
+// Mailer delivers transactional email. Implementations must be safe for
+// concurrent use.
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+type Config struct {
+	Driver   string // smtp, log or file
+	From     string
+	SMTPHost string
+	SMTPPort string
+	Username string
+	Password string
+	Dir      string // for the file driver
+}
+
+func New(cfg Config) (Mailer, error) {
+	if _, err := mail.ParseAddress(cfg.From); err != nil {
+		return nil, fmt.Errorf("mailer: invalid from address %q: %w", cfg.From, err)
+	}
+
+	switch cfg.Driver {
+	case "smtp":
+		return NewSMTP(cfg), nil
+	case "file":
+		return NewFile(cfg.From, cfg.Dir)
+	case "log", "":
+		return NewLog(), nil
+	}
+	return nil, fmt.Errorf("mailer: unknown driver %q", cfg.Driver)
+}
+
+// compose renders msg as an RFC 5322 message.
+func compose(from string, msg Message) ([]byte, error) {
+	to, err := mail.ParseAddress(msg.To)
+	if err != nil {
+		return nil, fmt.Errorf("mailer: invalid recipient %q: %w", msg.To, err)
+	}
+	sender, err := mail.ParseAddress(from)
+	if err != nil {
+		return nil, err
+	}
+
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+	domain := sender.Address[strings.LastIndex(sender.Address, "@")+1:]
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", sender.String())
+	fmt.Fprintf(&buf, "To: %s\r\n", to.String())
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&buf, "Message-ID: <%s@%s>\r\n", hex.EncodeToString(id), domain)
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	buf.WriteString("Content-Transfer-Encoding: 8bit\r\n")
+	buf.WriteString("\r\n")
+	buf.WriteString(strings.ReplaceAll(msg.Body, "\n", "\r\n"))
+
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,57 @@
+package mailer
+
+import (
+	"context"
+	"net"
+	"net/mail"
+	"net/smtp"
+)
+
+// SMTPMailer sends through an SMTP server, upgrading to TLS when the server
+// offers STARTTLS. Authentication is only attempted when a username is set,
+// so it works unchanged against local catchers such as MailHog.
+type SMTPMailer struct {
+	from     string
+	addr     string
+	host     string
+	username string
+	password string
+}
+
+func NewSMTP(cfg Config) *SMTPMailer {
+	return &SMTPMailer{
+		from:     cfg.From,
+		addr:     net.JoinHostPort(cfg.SMTPHost, cfg.SMTPPort),
+		host:     cfg.SMTPHost,
+		username: cfg.Username,
+		password: cfg.Password,
+	}
+}
+
+func (m *SMTPMailer) Send(ctx context.Context, msg Message) error {
+	body, err := compose(m.from, msg)
+	if err != nil {
+		return err
+	}
+
+	sender, _ := mail.ParseAddress(m.from)
+	to, _ := mail.ParseAddress(msg.To)
+
+	var auth smtp.Auth
+	if m.username != "" {
+		auth = smtp.PlainAuth("", m.username, m.password, m.host)
+	}
+
+	// net/smtp has no context support; run it aside so callers can give up
+	done := make(chan error, 1)
+	go func() {
+		done <- smtp.SendMail(m.addr, auth, sender.Address, []string{to.Address}, body)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,134 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+)
+
+// Metrics go to Prometheus' default registry, which also carries the Go
+// runtime and process collectors. Each service is its own scrape job, so
+// there is no service label.
+
+var (
+	httpRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_total",
+		Help: "HTTP requests handled, by route and status code.",
+	}, []string{"method", "route", "status"})
+
+	httpDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "Time taken to handle HTTP requests, by route.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route"})
+
+	httpInFlight = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "http_requests_in_flight",
+		Help: "HTTP requests currently being handled.",
+	})
+
+	cacheLookups = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "cache_lookups_total",
+		Help: "Cache lookups, by cache and result (hit or miss).",
+	}, []string{"cache", "result"})
+)
+
+// routeKey is where SetRoute leaves a route label for Middleware.
+const routeKey = "metricsRoute"
+
+// unmatchedRoute labels requests no route handled, so scanners probing
+// random paths cannot blow up the number of series.
+const unmatchedRoute = "unmatched"
+
+// Middleware records the rate, errors and duration of requests. Requests
+// are labelled with gin's route pattern, e.g. /api/v1/news/:id.
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		httpInFlight.Inc()
+		defer httpInFlight.Dec()
+
+		c.Next()
+
+		route := c.FullPath()
+		if value := c.GetString(routeKey); value != "" {
+			route = value
+		}
+		if route == "" {
+			route = unmatchedRoute
+		}
+
+		httpRequests.WithLabelValues(c.Request.Method, route, strconv.Itoa(c.Writer.Status())).Inc()
+		httpDuration.WithLabelValues(c.Request.Method, route).Observe(time.Since(start).Seconds())
+	}
+}
+
+// SetRoute labels the request with route, for handlers that route requests
+// themselves and so have no gin pattern.
+func SetRoute(c *gin.Context, route string) {
+	c.Set(routeKey, route)
+}
+
+// Handler serves the metrics for Prometheus to scrape.
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// CacheLookup counts a hit or miss on the named cache.
+func CacheLookup(cache string, hit bool) {
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+	cacheLookups.WithLabelValues(cache, result).Inc()
+}
+
+// RegisterDB exports the connection pool stats of db.
+func RegisterDB(db *gorm.DB, name string) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	return prometheus.Register(collectors.NewDBStatsCollector(sqlDB, name))
+}
+
+// RegisterRedis exports the connection pool stats of rdb.
+func RegisterRedis(rdb *redis.Client) error {
+	stats := []struct {
+		name  string
+		help  string
+		value func(*redis.PoolStats) uint32
+		gauge bool
+	}{
+		{"redis_pool_hits_total", "Times a free connection was found in the pool.", func(s *redis.PoolStats) uint32 { return s.Hits }, false},
+		{"redis_pool_misses_total", "Times no free connection was found in the pool.", func(s *redis.PoolStats) uint32 { return s.Misses }, false},
+		{"redis_pool_timeouts_total", "Times waiting for a connection timed out.", func(s *redis.PoolStats) uint32 { return s.Timeouts }, false},
+		{"redis_pool_connections", "Connections in the pool.", func(s *redis.PoolStats) uint32 { return s.TotalConns }, true},
+		{"redis_pool_idle_connections", "Idle connections in the pool.", func(s *redis.PoolStats) uint32 { return s.IdleConns }, true},
+		{"redis_pool_stale_connections_total", "Stale connections removed from the pool.", func(s *redis.PoolStats) uint32 { return s.StaleConns }, false},
+	}
+
+	for _, stat := range stats {
+		value := stat.value
+		read := func() float64 { return float64(value(rdb.PoolStats())) }
+
+		var collector prometheus.Collector
+		if stat.gauge {
+			collector = prometheus.NewGaugeFunc(prometheus.GaugeOpts{Name: stat.name, Help: stat.help}, read)
+		} else {
+			collector = prometheus.NewCounterFunc(prometheus.CounterOpts{Name: stat.name, Help: stat.help}, read)
+		}
+		if err := prometheus.Register(collector); err != nil {
+			return err
+		}
+	}
+	return nil
+}
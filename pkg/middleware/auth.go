@@ -1,99 +1,200 @@
 package middleware
 
 import (
-	"context"
 	"fmt"
 	"net/http"
 	"strings"
-	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/redis/go-redis/v9"
-	"golang.org/x/time/rate"
+
+	"news-aggregator/pkg/apikey"
+	"news-aggregator/pkg/ratelimit"
+	"news-aggregator/pkg/rbac"
+	"news-aggregator/pkg/revocation"
 )
 
+// SigningMethods are the algorithms access tokens may be signed with. HMAC is
+// deliberately absent: a shared secret would let any verifier mint tokens.
+var SigningMethods = []string{"RS256", "EdDSA"}
+
+// APIKeyHeader carries an API key, accepted wherever JWTAuth runs.
+const APIKeyHeader = "X-API-Key"
+
 type AuthMiddleware struct {
-	jwtSecret string
-	redis     *redis.Client
+	keys       jwt.Keyfunc
+	redis      *redis.Client
+	revocation *revocation.Store
+	apiKeys    *apikey.Store
+	limiter    *ratelimit.Limiter
 }
 
-func NewAuthMiddleware(jwtSecret string, redisClient *redis.Client) *AuthMiddleware {
+// NewAuthMiddleware verifies tokens with keys, typically a jwks.Client's
+// Keyfunc pointed at the auth service.
+func NewAuthMiddleware(keys jwt.Keyfunc, redisClient *redis.Client) *AuthMiddleware {
 	return &AuthMiddleware{
-		jwtSecret: jwtSecret,
-		redis:     redisClient,
+		keys:       keys,
+		redis:      redisClient,
+		revocation: revocation.New(redisClient),
+		apiKeys:    apikey.New(redisClient),
+		limiter:    ratelimit.New(redisClient),
 	}
 }
 
+// JWTAuth authenticates the request with a bearer token or, failing that, an
+// X-API-Key header.
 func (a *AuthMiddleware) JWTAuth() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		authHeader := c.GetHeader("Authorization")
-		if authHeader == "" {
+		if c.GetHeader(APIKeyHeader) != "" && c.GetHeader("Authorization") == "" {
+			a.apiKeyAuth(c)
+			return
+		}
+
+		if c.GetHeader("Authorization") == "" {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header required"})
 			c.Abort()
 			return
 		}
 
-		tokenString := strings.Replace(authHeader, "Bearer ", "", 1)
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-			}
-			return []byte(a.jwtSecret), nil
-		})
-
-		if err != nil || !token.Valid {
+		claims := a.bearerClaims(c)
+		if claims == nil {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
 			c.Abort()
 			return
 		}
 
-		if claims, ok := token.Claims.(jwt.MapClaims); ok {
-			c.Set("userID", claims["userID"])
-			c.Set("username", claims["username"])
-			c.Set("role", claims["role"])
+		// JSON numbers decode as float64; handlers expect the uint ID
+		userID, _ := claims["userID"].(float64)
+		jti, _ := claims["jti"].(string)
+		sid, _ := claims["sid"].(string)
+
+		revoked, err := a.revocation.IsRevoked(c.Request.Context(), jti, sid, uint(userID), revocation.IssuedAt(claims))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Token revocation check failed"})
+			c.Abort()
+			return
 		}
+		if revoked {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Token revoked"})
+			c.Abort()
+			return
+		}
+
+		c.Set("userID", uint(userID))
+		c.Set("username", claims["username"])
+		c.Set("role", claims["role"])
+		c.Set("claims", claims)
 
 		c.Next()
 	}
 }
 
-func (a *AuthMiddleware) RateLimit(rps int, window time.Duration) gin.HandlerFunc {
-	limiter := rate.NewLimiter(rate.Limit(rps), rps)
+func (a *AuthMiddleware) apiKeyAuth(c *gin.Context) {
+	identity, err := a.apiKey(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "API key check failed"})
+		c.Abort()
+		return
+	}
+	if identity == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid API key"})
+		c.Abort()
+		return
+	}
 
-	return func(c *gin.Context) {
-		clientIP := c.ClientIP()
-		key := fmt.Sprintf("rate_limit:%s", clientIP)
+	c.Set("userID", identity.UserID)
+	c.Set("username", identity.Username)
+	c.Set("role", identity.Role)
+	c.Set("scopes", identity.Scopes)
 
-		ctx := context.Background()
+	c.Next()
+}
 
-		// Check current count
-		current, err := a.redis.Get(ctx, key).Int()
-		if err != nil && err != redis.Nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Rate limit check failed"})
-			c.Abort()
-			return
+// apiKey resolves the request's X-API-Key, remembering the result so the rate
+// limiter and JWTAuth only look it up once.
+func (a *AuthMiddleware) apiKey(c *gin.Context) (*apikey.Identity, error) {
+	if value, ok := c.Get("apiKey"); ok {
+		return value.(*apikey.Identity), nil
+	}
+
+	identity, err := a.apiKeys.Lookup(c.Request.Context(), c.GetHeader(APIKeyHeader))
+	if err != nil {
+		return nil, err
+	}
+	c.Set("apiKey", identity)
+	return identity, nil
+}
+
+// bearerClaims verifies the request's bearer token and returns its claims,
+// or nil if it is missing or invalid. Like apiKey it remembers the result,
+// so the signature is checked once even when the rate limiter looks first.
+func (a *AuthMiddleware) bearerClaims(c *gin.Context) jwt.MapClaims {
+	if value, ok := c.Get("bearerClaims"); ok {
+		return value.(jwt.MapClaims)
+	}
+
+	var claims jwt.MapClaims
+	if authHeader := c.GetHeader("Authorization"); authHeader != "" {
+		tokenString := strings.Replace(authHeader, "Bearer ", "", 1)
+		token, err := jwt.Parse(tokenString, a.keys, jwt.WithValidMethods(SigningMethods))
+		if err == nil && token.Valid {
+			claims, _ = token.Claims.(jwt.MapClaims)
+		}
+	}
+
+	c.Set("bearerClaims", claims)
+	return claims
+}
+
+// UserID returns the authenticated user's ID as set by JWTAuth.
+func UserID(c *gin.Context) (uint, bool) {
+	value, ok := c.Get("userID")
+	if !ok {
+		return 0, false
+	}
+	userID, ok := value.(uint)
+	return userID, ok && userID != 0
+}
+
+// RequirePermission must run after JWTAuth, which puts the role claim on the
+// context. The caller's role needs every listed permission, and an API key
+// also needs each of them among its scopes.
+func RequirePermission(permissions ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		role := c.GetString("role")
+		for _, permission := range permissions {
+			if !rbac.Has(role, permission) || !hasScope(c, permission) {
+				c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+				c.Abort()
+				return
+			}
 		}
 
-		// Use Redis for distributed rate limiting
-		if current >= rps {
-			c.JSON(http.StatusTooManyRequests, gin.H{
-				"error":       "Rate limit exceeded",
-				"retry_after": int(window.Seconds()),
-			})
+		c.Next()
+	}
+}
+
+// RequireScope limits API keys to routes they were granted; requests with a
+// token pass through.
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !hasScope(c, scope) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "API key lacks scope " + scope})
 			c.Abort()
 			return
 		}
 
-		// Increment counter
-		pipe := a.redis.Pipeline()
-		pipe.Incr(ctx, key)
-		pipe.Expire(ctx, key, window)
-		pipe.Exec(ctx)
+		c.Next()
+	}
+}
 
-		// Also use local rate limiter as backup
-		if !limiter.Allow() {
-			c.JSON(http.StatusTooManyRequests, gin.H{"error": "Rate limit exceeded"})
+// SessionOnly rejects API keys, for account management a key must never be
+// able to do on its own.
+func SessionOnly() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, ok := c.Get("scopes"); ok {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Not available with an API key"})
 			c.Abort()
 			return
 		}
@@ -102,11 +203,24 @@ func (a *AuthMiddleware) RateLimit(rps int, window time.Duration) gin.HandlerFun
 	}
 }
 
+func hasScope(c *gin.Context, scope string) bool {
+	value, ok := c.Get("scopes")
+	if !ok {
+		return true
+	}
+	for _, s := range value.([]string) {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
 func CORS() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		c.Header("Access-Control-Allow-Origin", "*")
-		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Header("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
+		c.Header("Access-Control-Allow-Headers", "Content-Type, Authorization, X-API-Key")
 
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(http.StatusNoContent)
@@ -0,0 +1,112 @@
+package middleware
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"news-aggregator/pkg/ratelimit"
+)
+
+// RateLimitOptions configures one RateLimit middleware.
+type RateLimitOptions struct {
+	// Scope separates buckets, so a route can have a limit of its own on top
+	// of the global one. Empty means the global bucket.
+	Scope string
+	Tiers ratelimit.Tiers
+	// FailOpen lets requests through while Redis is unreachable instead of
+	// answering 503
+	FailOpen bool
+}
+
+// RateLimit counts requests per caller: the API key, else the user of a
+// valid bearer token, else the client IP. Users get the limit of their
+// role's tier. In the global scope an API key is held to its own quota.
+func (a *AuthMiddleware) RateLimit(opts RateLimitOptions) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		result, err := a.countRequest(c, opts)
+		if err != nil {
+			if opts.FailOpen {
+				log.Printf("Rate limit check failed, letting request through: %v", err)
+				c.Next()
+				return
+			}
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Rate limit check failed"})
+			c.Abort()
+			return
+		}
+
+		setRateLimitHeaders(c, result)
+		if !result.Allowed {
+			retryAfter := seconds(result.RetryAfter)
+			c.Header("Retry-After", strconv.Itoa(retryAfter))
+			c.JSON(http.StatusTooManyRequests, gin.H{
+				"error":       "Rate limit exceeded",
+				"retry_after": retryAfter,
+			})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+func (a *AuthMiddleware) countRequest(c *gin.Context, opts RateLimitOptions) (ratelimit.Result, error) {
+	key, limit, err := a.rateLimitKey(c, opts)
+	if err != nil {
+		return ratelimit.Result{}, err
+	}
+	if opts.Scope != "" {
+		key = opts.Scope + ":" + key
+	}
+	return a.limiter.Allow(c.Request.Context(), key, limit)
+}
+
+func (a *AuthMiddleware) rateLimitKey(c *gin.Context, opts RateLimitOptions) (string, ratelimit.Limit, error) {
+	if c.GetHeader(APIKeyHeader) != "" && c.GetHeader("Authorization") == "" {
+		identity, err := a.apiKey(c)
+		if err != nil {
+			return "", ratelimit.Limit{}, err
+		}
+		if identity != nil {
+			limit := opts.Tiers.For(identity.Role)
+			if opts.Scope == "" {
+				limit.Requests = identity.RateLimit
+			}
+			return fmt.Sprintf("key:%d", identity.ID), limit, nil
+		}
+	}
+
+	if claims := a.bearerClaims(c); claims != nil {
+		userID, _ := claims["userID"].(float64)
+		role, _ := claims["role"].(string)
+		return fmt.Sprintf("user:%d", uint(userID)), opts.Tiers.For(role), nil
+	}
+
+	return "ip:" + c.ClientIP(), opts.Tiers.Default, nil
+}
+
+// setRateLimitHeaders writes the RateLimit-* headers. With a global and a
+// route limit in play, the one closer to running out is reported.
+func setRateLimitHeaders(c *gin.Context, result ratelimit.Result) {
+	if current := c.Writer.Header().Get("RateLimit-Remaining"); current != "" {
+		if remaining, err := strconv.Atoi(current); err == nil && remaining < result.Remaining {
+			return
+		}
+	}
+
+	c.Header("RateLimit-Limit", strconv.Itoa(result.Limit))
+	c.Header("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+	c.Header("RateLimit-Reset", strconv.Itoa(seconds(result.ResetAfter)))
+}
+
+// seconds rounds up, so a client waiting that long is sure to get through.
+func seconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,38 @@
+package models
+
+import (
+	"time"
+)
+
+// APIKey lets machine clients call the API as their owner, limited to the
+// key's scopes. Only the SHA-256 of the key is stored; KeyPrefix is kept so
+// users can tell their keys apart.
+type APIKey struct {
+	ID        uint       `json:"id" gorm:"primaryKey"`
+	UserID    uint       `json:"user_id" gorm:"index;not null"`
+	Name      string     `json:"name" gorm:"not null"`
+	KeyPrefix string     `json:"key_prefix" gorm:"not null"`
+	KeyHash   string     `json:"-" gorm:"uniqueIndex;not null"`
+	Scopes    []string   `json:"scopes" gorm:"serializer:json"`
+	RateLimit int        `json:"rate_limit" gorm:"not null"`
+	ExpiresAt *time.Time `json:"expires_at"`
+	RevokedAt *time.Time `json:"revoked_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+type CreateAPIKeyRequest struct {
+	Name          string   `json:"name" binding:"required,max=100"`
+	Scopes        []string `json:"scopes" binding:"required,min=1"`
+	ExpiresInDays int      `json:"expires_in_days" binding:"omitempty,min=1"`
+	RateLimit     int      `json:"rate_limit" binding:"omitempty,min=1"`
+}
+
+// CreateAPIKeyResponse is the only time the key itself is returned.
+type CreateAPIKeyResponse struct {
+	APIKey
+	Key string `json:"key"`
+}
+
+type UpdateAPIKeyQuotaRequest struct {
+	RateLimit int `json:"rate_limit" binding:"required,min=1"`
+}
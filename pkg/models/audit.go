@@ -0,0 +1,35 @@
+package models
+
+import (
+	"time"
+)
+
+const (
+	AuditLoginLocked   = "login.locked"
+	AuditLoginUnlocked = "login.unlocked"
+
+	AuditMFAEnabled      = "mfa.enabled"
+	AuditMFADisabled     = "mfa.disabled"
+	AuditMFARecoveryUsed = "mfa.recovery_used"
+
+	AuditOIDCLinked = "oidc.linked"
+
+	AuditRoleChanged = "role.changed"
+
+	AuditPasswordChanged = "password.changed"
+	AuditEmailChanged    = "email.changed"
+	AuditAccountDeleted  = "account.deleted"
+)
+
+// AuditLog records security-relevant events. UserID is empty when the event
+// concerns a username that may not exist.
+type AuditLog struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	Event     string    `json:"event" gorm:"index;not null"`
+	UserID    *uint     `json:"user_id,omitempty" gorm:"index"`
+	ActorID   *uint     `json:"actor_id,omitempty"`
+	Username  string    `json:"username,omitempty"`
+	IP        string    `json:"ip,omitempty"`
+	Details   string    `json:"details,omitempty"`
+	CreatedAt time.Time `json:"created_at" gorm:"index"`
+}
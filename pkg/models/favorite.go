@@ -0,0 +1,19 @@
+package models
+
+import (
+	"time"
+)
+
+type UserFavorite struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	UserID    uint      `json:"user_id" gorm:"not null;uniqueIndex:idx_user_favorites_user_news"`
+	NewsID    uint      `json:"news_id" gorm:"not null;uniqueIndex:idx_user_favorites_user_news;index"`
+	News      *News     `json:"news,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type FavoriteStatus struct {
+	NewsID        uint  `json:"news_id"`
+	Favorited     bool  `json:"favorited"`
+	FavoriteCount int64 `json:"favorite_count"`
+}
@@ -0,0 +1,17 @@
+package models
+
+import (
+	"time"
+)
+
+// UserIdentity links a user to an account at an external OpenID Connect
+// provider. Subject is the provider's stable ID for that account.
+type UserIdentity struct {
+	ID          uint       `json:"id" gorm:"primaryKey"`
+	UserID      uint       `json:"user_id" gorm:"index;not null"`
+	Provider    string     `json:"provider" gorm:"not null;uniqueIndex:idx_identity_provider_subject"`
+	Subject     string     `json:"subject" gorm:"not null;uniqueIndex:idx_identity_provider_subject"`
+	Email       string     `json:"email"`
+	LastLoginAt *time.Time `json:"last_login_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
@@ -0,0 +1,46 @@
+package models
+
+import (
+	"time"
+)
+
+// RecoveryCode is a one-time fallback for a lost authenticator. Only the
+// SHA-256 of the code is stored.
+type RecoveryCode struct {
+	ID        uint   `gorm:"primaryKey"`
+	UserID    uint   `gorm:"index;not null"`
+	CodeHash  string `gorm:"uniqueIndex;not null"`
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+type TOTPEnrollResponse struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioning_uri"`
+}
+
+type TOTPCodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+type TOTPDisableRequest struct {
+	Password string `json:"password" binding:"required"`
+	Code     string `json:"code" binding:"required"` // TOTP or recovery code
+}
+
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// MFAChallengeResponse is what /login returns instead of tokens when the
+// account has two-factor authentication on.
+type MFAChallengeResponse struct {
+	MFARequired bool   `json:"mfa_required"`
+	MFAToken    string `json:"mfa_token"`
+	ExpiresIn   int64  `json:"expires_in"`
+}
+
+type MFALoginRequest struct {
+	MFAToken string `json:"mfa_token" binding:"required"`
+	Code     string `json:"code" binding:"required"` // TOTP or recovery code
+}
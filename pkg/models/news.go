@@ -7,26 +7,48 @@ import (
 )
 
 type News struct {
-	ID          uint           `json:"id" gorm:"primaryKey"`
-	Title       string         `json:"title" gorm:"not null"`
-	Description string         `json:"description"`
-	URL         string         `json:"url" gorm:"unique;not null"`
-	Source      string         `json:"source" gorm:"not null"`
-	PublishedAt time.Time      `json:"published_at"`
-	CreatedAt   time.Time      `json:"created_at"`
-	UpdatedAt   time.Time      `json:"updated_at"`
-	DeletedAt   gorm.DeletedAt `json:"-" gorm:"index"`
+	ID            uint           `json:"id" gorm:"primaryKey"`
+	Title         string         `json:"title" gorm:"not null"`
+	Description   string         `json:"description"`
+	URL           string         `json:"url" gorm:"unique;not null"`
+	SourceID      *uint          `json:"source_id" gorm:"index"`
+	Source        *Source        `json:"source,omitempty"`
+	Author        string         `json:"author,omitempty"`
+	Content       string         `json:"content,omitempty" gorm:"type:text"`
+	ContentHTML   string         `json:"content_html,omitempty" gorm:"type:text"`
+	LeadImage     string         `json:"lead_image,omitempty"`
+	WordCount     int            `json:"word_count,omitempty"`
+	ReadingTime   int            `json:"reading_time,omitempty"` // minutes
+	ExtractedAt   *time.Time     `json:"extracted_at,omitempty"`
+	FavoriteCount int64          `json:"favorite_count" gorm:"not null;default:0"`
+	SearchConfig  string         `json:"-" gorm:"type:regconfig;not null;default:'simple'"` // copied from the source
+	PublishedAt   time.Time      `json:"published_at"`
+	CreatedAt     time.Time      `json:"created_at"`
+	UpdatedAt     time.Time      `json:"updated_at"`
+	DeletedAt     gorm.DeletedAt `json:"-" gorm:"index"`
 }
 
+const (
+	UserStatusActive    = "active"
+	UserStatusSuspended = "suspended"
+)
+
 type User struct {
-	ID        uint           `json:"id" gorm:"primaryKey"`
-	Username  string         `json:"username" gorm:"unique;not null"`
-	Email     string         `json:"email" gorm:"unique;not null"`
-	Password  string         `json:"-" gorm:"not null"`
-	Role      string         `json:"role" gorm:"default:user"`
-	CreatedAt time.Time      `json:"created_at"`
-	UpdatedAt time.Time      `json:"updated_at"`
-	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
+	ID              uint           `json:"id" gorm:"primaryKey"`
+	Username        string         `json:"username" gorm:"unique;not null"`
+	Email           string         `json:"email" gorm:"unique;not null"`
+	Password        string         `json:"-" gorm:"not null"`
+	Role            string         `json:"role" gorm:"default:user"`
+	Status          string         `json:"status" gorm:"not null;default:active"`
+	EmailVerifiedAt *time.Time     `json:"email_verified_at,omitempty"`
+	TOTPEnabled     bool           `json:"totp_enabled" gorm:"column:totp_enabled;not null;default:false"`
+	TOTPSecret      string         `json:"-" gorm:"column:totp_secret"`
+	TOTPLastStep    int64          `json:"-" gorm:"column:totp_last_step;not null;default:0"`
+	SuspendedAt     *time.Time     `json:"suspended_at,omitempty"`
+	SuspendReason   string         `json:"suspend_reason,omitempty"`
+	CreatedAt       time.Time      `json:"created_at"`
+	UpdatedAt       time.Time      `json:"updated_at"`
+	DeletedAt       gorm.DeletedAt `json:"-" gorm:"index"`
 }
 
 type NewsResponse struct {
@@ -36,6 +58,41 @@ type NewsResponse struct {
 	Limit int    `json:"limit"`
 }
 
+// NewsSearchResult is one hit from full-text search. Headline is an
+// HTML-escaped plain-text excerpt with the matched terms wrapped in <mark>.
+type NewsSearchResult struct {
+	News
+	Rank     float64 `json:"rank"`
+	Headline string  `json:"headline"`
+}
+
+type NewsSearchResponse struct {
+	Data  []NewsSearchResult `json:"data"`
+	Query string             `json:"query"`
+	Total int64              `json:"total"`
+	Page  int                `json:"page"`
+	Limit int                `json:"limit"`
+}
+
+// MigrateSearch adds the full-text search vector. It is a generated column so
+// every writer keeps it current without knowing it exists, and it is built
+// with the row's own search_config so stemming follows the source language.
+// The scraper and news-api both run it, as either may start first.
+func MigrateSearch(db *gorm.DB) error {
+	if !db.Migrator().HasColumn("news", "search_vector") {
+		if err := db.Exec(`ALTER TABLE news ADD COLUMN IF NOT EXISTS search_vector tsvector
+			GENERATED ALWAYS AS (
+				setweight(to_tsvector(search_config, coalesce(title, '')), 'A') ||
+				setweight(to_tsvector(search_config, coalesce(description, '')), 'B') ||
+				setweight(to_tsvector(search_config, coalesce(content, '')), 'C')
+			) STORED`).Error; err != nil {
+			return err
+		}
+	}
+
+	return db.Exec("CREATE INDEX IF NOT EXISTS idx_news_search_vector ON news USING GIN (search_vector)").Error
+}
+
 type LoginRequest struct {
 	Username string `json:"username" binding:"required"`
 	Password string `json:"password" binding:"required"`
@@ -47,7 +104,17 @@ type RegisterRequest struct {
 	Password string `json:"password" binding:"required,min=6"`
 }
 
+type UpdateRoleRequest struct {
+	Role string `json:"role" binding:"required"`
+}
+
+type SuspendUserRequest struct {
+	Reason string `json:"reason"`
+}
+
 type AuthResponse struct {
-	Token string `json:"token"`
-	User  User   `json:"user"`
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	ExpiresIn    int64  `json:"expires_in,omitempty"` // access token lifetime, seconds
+	User         User   `json:"user"`
 }
@@ -0,0 +1,23 @@
+package models
+
+import (
+	"time"
+)
+
+// OutboxEvent is a message waiting to be published to Kafka. Rows are
+// written in the same transaction as the change they describe and relayed
+// by the scraper, so an event exists if and only if the change committed.
+type OutboxEvent struct {
+	ID            uint   `gorm:"primaryKey"`
+	EventID       string `gorm:"uniqueIndex;not null"`
+	EventType     string `gorm:"not null;default:''"`
+	SchemaVersion int    `gorm:"not null;default:1"`
+	Topic         string `gorm:"not null"`
+	Key           string `gorm:"not null"`
+	Payload       []byte `gorm:"not null"`
+	Attempts      int    `gorm:"not null;default:0"`
+	LastError     string
+	NextAttemptAt time.Time  `gorm:"index;not null"`
+	PublishedAt   *time.Time `gorm:"index"`
+	CreatedAt     time.Time
+}
@@ -0,0 +1,35 @@
+package models
+
+import (
+	"time"
+)
+
+// UpdateProfileRequest changes only the fields that are present.
+// CurrentPassword is required to change the email.
+type UpdateProfileRequest struct {
+	Username        *string `json:"username" binding:"omitempty,min=3,max=50"`
+	Email           *string `json:"email" binding:"omitempty,email"`
+	CurrentPassword string  `json:"current_password"`
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	NewPassword     string `json:"new_password" binding:"required,min=6"`
+}
+
+type DeleteAccountRequest struct {
+	Password string `json:"password" binding:"required"`
+	Code     string `json:"code"` // required when two-factor authentication is on
+}
+
+// UserExport is everything stored about a user, for data-protection
+// requests. Secrets such as password and key hashes are left out.
+type UserExport struct {
+	ExportedAt time.Time      `json:"exported_at"`
+	User       User           `json:"user"`
+	Identities []UserIdentity `json:"identities"`
+	Sessions   []Session      `json:"sessions"`
+	APIKeys    []APIKey       `json:"api_keys"`
+	Favorites  []UserFavorite `json:"favorites"`
+	AuditLogs  []AuditLog     `json:"audit_logs"`
+}
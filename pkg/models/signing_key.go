@@ -0,0 +1,20 @@
+package models
+
+import (
+	"time"
+)
+
+// SigningKey is an auth-service key pair for access tokens. A key is
+// published in the JWKS as soon as it exists, signs from ActiveAt until a
+// newer key takes over, and is dropped once ExpiresAt passes and no token
+// it signed can still be valid.
+type SigningKey struct {
+	ID         uint       `gorm:"primaryKey"`
+	KID        string     `gorm:"column:kid;uniqueIndex;not null"`
+	Algorithm  string     `gorm:"not null"`
+	PrivateKey []byte     `gorm:"not null"` // PKCS#8 DER, AES-GCM sealed when Encrypted
+	Encrypted  bool       `gorm:"not null;default:false"`
+	ActiveAt   time.Time  `gorm:"not null"`
+	ExpiresAt  *time.Time `gorm:"index"`
+	CreatedAt  time.Time
+}
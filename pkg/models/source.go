@@ -0,0 +1,57 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	FetchStatusOK    = "ok"
+	FetchStatusError = "error"
+)
+
+type Source struct {
+	ID              uint           `json:"id" gorm:"primaryKey"`
+	URL             string         `json:"url" gorm:"unique;not null"`
+	Name            string         `json:"name" gorm:"not null"`
+	Category        string         `json:"category"`
+	Language        string         `json:"language"`
+	SearchConfig    string         `json:"search_config" gorm:"not null;default:simple"` // Postgres text search configuration
+	Enabled         bool           `json:"enabled" gorm:"not null"`
+	PollInterval    int            `json:"poll_interval" gorm:"not null;default:300"` // seconds
+	ExtractContent  bool           `json:"extract_content" gorm:"not null;default:false"`
+	FetchInterval   int            `json:"fetch_interval"` // adaptive, seconds
+	NextFetchAt     *time.Time     `json:"next_fetch_at" gorm:"index"`
+	FailureCount    int            `json:"failure_count" gorm:"not null;default:0"`
+	ETag            string         `json:"-"`
+	LastModified    string         `json:"-"`
+	LastFetchedAt   *time.Time     `json:"last_fetched_at"`
+	LastFetchStatus string         `json:"last_fetch_status"`
+	LastFetchError  string         `json:"last_fetch_error,omitempty"`
+	CreatedAt       time.Time      `json:"created_at"`
+	UpdatedAt       time.Time      `json:"updated_at"`
+	DeletedAt       gorm.DeletedAt `json:"-" gorm:"index"`
+}
+
+type CreateSourceRequest struct {
+	URL            string `json:"url" binding:"required,url"`
+	Name           string `json:"name"`
+	Category       string `json:"category"`
+	Language       string `json:"language"`
+	SearchConfig   string `json:"search_config"`
+	Enabled        *bool  `json:"enabled"`
+	PollInterval   int    `json:"poll_interval" binding:"omitempty,min=60"`
+	ExtractContent bool   `json:"extract_content"`
+}
+
+type UpdateSourceRequest struct {
+	URL            *string `json:"url" binding:"omitempty,url"`
+	Name           *string `json:"name"`
+	Category       *string `json:"category"`
+	Language       *string `json:"language"`
+	SearchConfig   *string `json:"search_config"`
+	Enabled        *bool   `json:"enabled"`
+	PollInterval   *int    `json:"poll_interval" binding:"omitempty,min=60"`
+	ExtractContent *bool   `json:"extract_content"`
+}
@@ -0,0 +1,76 @@
+package models
+
+import (
+	"time"
+)
+
+// RefreshToken is one link in a rotation chain. Only the SHA-256 of the
+// opaque token is stored. Every token minted from the same login shares a
+// FamilyID, so replaying a used token can revoke the whole chain.
+type RefreshToken struct {
+	ID        uint       `gorm:"primaryKey"`
+	UserID    uint       `gorm:"index;not null"`
+	FamilyID  string     `gorm:"index;not null"`
+	TokenHash string     `gorm:"uniqueIndex;not null"`
+	ExpiresAt time.Time  `gorm:"index;not null"`
+	UsedAt    *time.Time // set when rotated
+	RevokedAt *time.Time
+	UserAgent string
+	IP        string
+	CreatedAt time.Time
+}
+
+// Session is one login on one device. Its ID is the refresh token family and
+// travels in access tokens as the sid claim. LastSeenAt moves with every
+// refresh.
+type Session struct {
+	ID         string     `json:"id" gorm:"primaryKey"`
+	UserID     uint       `json:"-" gorm:"index;not null"`
+	UserAgent  string     `json:"user_agent"`
+	IP         string     `json:"ip"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastSeenAt time.Time  `json:"last_seen_at"`
+	ExpiresAt  time.Time  `json:"expires_at" gorm:"index;not null"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	Current    bool       `json:"current" gorm:"-"`
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+// LogoutRequest optionally names the refresh token to revoke along with the
+// access token.
+type LogoutRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+const (
+	TokenPurposePasswordReset = "password_reset"
+	TokenPurposeVerifyEmail   = "verify_email"
+)
+
+// UserToken is a single-use token mailed to a user, e.g. for a password
+// reset. Like refresh tokens, only the hash is stored.
+type UserToken struct {
+	ID        uint      `gorm:"primaryKey"`
+	UserID    uint      `gorm:"index;not null"`
+	Purpose   string    `gorm:"not null"`
+	TokenHash string    `gorm:"uniqueIndex;not null"`
+	ExpiresAt time.Time `gorm:"index;not null"`
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required,min=6"`
+}
+
+type VerifyEmailRequest struct {
+	Token string `json:"token" binding:"required"`
+}
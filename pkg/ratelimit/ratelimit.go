@@ -0,0 +1,137 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Limit allows Requests per Window, at most Requests of them back to back.
+type Limit struct {
+	Requests int
+	Window   time.Duration
+}
+
+func (l Limit) validate() error {
+	if l.Requests <= 0 || l.Window <= 0 {
+		return fmt.Errorf("invalid rate limit of %d requests per %s", l.Requests, l.Window)
+	}
+	return nil
+}
+
+// Tiers picks a limit by role; roles without an entry get Default.
+type Tiers struct {
+	Default Limit
+	Roles   map[string]Limit
+}
+
+func (t Tiers) For(role string) Limit {
+	if limit, ok := t.Roles[role]; ok {
+		return limit
+	}
+	return t.Default
+}
+
+// ParseTiers builds Tiers from a default and a spec such as
+// "moderator=300,admin=1000", each count applying per window.
+func ParseTiers(requests int, window time.Duration, spec string) (Tiers, error) {
+	if err := (Limit{Requests: requests, Window: window}).validate(); err != nil {
+		return Tiers{}, err
+	}
+
+	tiers := Tiers{
+		Default: Limit{Requests: requests, Window: window},
+		Roles:   make(map[string]Limit),
+	}
+
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		role, count, ok := strings.Cut(entry, "=")
+		n, err := strconv.Atoi(strings.TrimSpace(count))
+		if !ok || err != nil || n <= 0 {
+			return Tiers{}, fmt.Errorf("invalid rate limit tier %q", entry)
+		}
+		tiers.Roles[strings.TrimSpace(role)] = Limit{Requests: n, Window: window}
+	}
+
+	return tiers, nil
+}
+
+// Result describes a bucket after a request was counted against it.
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// RetryAfter is how long a denied caller has to wait
+	RetryAfter time.Duration
+	// ResetAfter is how long until the bucket is full again
+	ResetAfter time.Duration
+}
+
+// gcra implements the generic cell rate algorithm in one round trip. The
+// bucket is a single timestamp, the theoretical arrival time (TAT) of the
+// next request, so checking and updating it cannot race between replicas.
+// Redis' clock is used so gateways with drifting clocks agree.
+var gcra = redis.NewScript(`
+local emission = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local time = redis.call('TIME')
+local now = tonumber(time[1]) * 1000000 + tonumber(time[2])
+
+local tat = tonumber(redis.call('GET', KEYS[1])) or now
+if tat < now then
+  tat = now
+end
+
+local new_tat = tat + emission
+local allow_at = new_tat - emission * burst
+if now < allow_at then
+  return {0, 0, allow_at - now, tat - now}
+end
+
+redis.call('SET', KEYS[1], new_tat, 'PX', math.ceil((new_tat - now) / 1000))
+return {1, math.floor((now - allow_at) / emission), 0, new_tat - now}
+`)
+
+// Limiter keeps GCRA buckets in Redis, shared by every service that uses
+// the same keys.
+type Limiter struct {
+	redis *redis.Client
+}
+
+func New(redisClient *redis.Client) *Limiter {
+	return &Limiter{redis: redisClient}
+}
+
+// Allow counts one request against key's bucket. A limit that allows no
+// requests is an error rather than a bucket that is always empty.
+func (l *Limiter) Allow(ctx context.Context, key string, limit Limit) (Result, error) {
+	if err := limit.validate(); err != nil {
+		return Result{}, err
+	}
+
+	emission := limit.Window.Microseconds() / int64(limit.Requests)
+	if emission < 1 {
+		emission = 1
+	}
+
+	values, err := gcra.Run(ctx, l.redis, []string{"rate_limit:" + key}, emission, limit.Requests).Int64Slice()
+	if err != nil {
+		return Result{}, err
+	}
+
+	return Result{
+		Allowed:    values[0] == 1,
+		Limit:      limit.Requests,
+		Remaining:  int(values[1]),
+		RetryAfter: time.Duration(values[2]) * time.Microsecond,
+		ResetAfter: time.Duration(values[3]) * time.Microsecond,
+	}, nil
+}
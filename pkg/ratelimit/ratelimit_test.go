@@ -0,0 +1,130 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func newLimiter(t *testing.T) (*Limiter, *miniredis.Miniredis) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	mr.SetTime(time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC))
+	return New(redis.NewClient(&redis.Options{Addr: mr.Addr()})), mr
+}
+
+func TestAllowBurstThenDeny(t *testing.T) {
+	ctx := context.Background()
+	limiter, _ := newLimiter(t)
+	limit := Limit{Requests: 3, Window: 3 * time.Second}
+
+	for i, want := range []int{2, 1, 0} {
+		result, err := limiter.Allow(ctx, "user:1", limit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !result.Allowed || result.Remaining != want {
+			t.Fatalf("request %d: allowed = %v, remaining = %d, want true, %d", i+1, result.Allowed, result.Remaining, want)
+		}
+		if result.Limit != 3 {
+			t.Errorf("limit = %d, want 3", result.Limit)
+		}
+	}
+
+	result, err := limiter.Allow(ctx, "user:1", limit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Allowed {
+		t.Fatal("fourth request in the burst was allowed")
+	}
+	if result.RetryAfter != time.Second {
+		t.Errorf("RetryAfter = %v, want 1s", result.RetryAfter)
+	}
+	if result.ResetAfter != 3*time.Second {
+		t.Errorf("ResetAfter = %v, want 3s", result.ResetAfter)
+	}
+
+	// Buckets are per key
+	if result, _ := limiter.Allow(ctx, "user:2", limit); !result.Allowed {
+		t.Error("another key was limited")
+	}
+}
+
+func TestAllowRefills(t *testing.T) {
+	ctx := context.Background()
+	limiter, mr := newLimiter(t)
+	limit := Limit{Requests: 2, Window: 2 * time.Second}
+	start := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	for i := 0; i < 2; i++ {
+		limiter.Allow(ctx, "ip:1", limit)
+	}
+	if result, _ := limiter.Allow(ctx, "ip:1", limit); result.Allowed {
+		t.Fatal("empty bucket allowed a request")
+	}
+
+	// One emission interval frees one request, not the whole burst
+	mr.SetTime(start.Add(time.Second))
+	if result, _ := limiter.Allow(ctx, "ip:1", limit); !result.Allowed || result.Remaining != 0 {
+		t.Fatalf("after one interval: allowed = %v, remaining = %d", result.Allowed, result.Remaining)
+	}
+	if result, _ := limiter.Allow(ctx, "ip:1", limit); result.Allowed {
+		t.Fatal("refill allowed more than one request")
+	}
+
+	mr.SetTime(start.Add(10 * time.Second))
+	if result, _ := limiter.Allow(ctx, "ip:1", limit); !result.Allowed || result.Remaining != 1 {
+		t.Errorf("after a full window: allowed = %v, remaining = %d", result.Allowed, result.Remaining)
+	}
+}
+
+func TestAllowRejectsInvalidLimits(t *testing.T) {
+	limiter, _ := newLimiter(t)
+
+	for _, limit := range []Limit{
+		{Requests: 0, Window: time.Minute},
+		{Requests: -1, Window: time.Minute},
+		{Requests: 10, Window: 0},
+	} {
+		if _, err := limiter.Allow(context.Background(), "user:1", limit); err == nil {
+			t.Errorf("Allow(%+v) succeeded", limit)
+		}
+	}
+}
+
+func TestParseTiers(t *testing.T) {
+	tests := []struct {
+		name     string
+		requests int
+		spec     string
+		want     map[string]int
+		wantErr  bool
+	}{
+		{"default only", 100, "", map[string]int{"user": 100}, false},
+		{"roles", 100, "moderator=300, admin=1000", map[string]int{"user": 100, "moderator": 300, "admin": 1000}, false},
+		{"trailing comma", 100, "admin=1000,", map[string]int{"admin": 1000}, false},
+		{"zero default", 0, "", nil, true},
+		{"negative default", -5, "admin=1000", nil, true},
+		{"zero tier", 100, "admin=0", nil, true},
+		{"missing count", 100, "admin", nil, true},
+		{"not a number", 100, "admin=lots", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tiers, err := ParseTiers(tt.requests, time.Minute, tt.spec)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseTiers() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			for role, want := range tt.want {
+				if got := tiers.For(role); got.Requests != want || got.Window != time.Minute {
+					t.Errorf("For(%q) = %+v, want %d per minute", role, got, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package rbac
+
+const (
+	RoleUser      = "user"
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+
+	PermNewsModerate  = "news:moderate"
+	PermSourcesManage = "sources:manage"
+	PermUsersAdmin    = "users:admin"
+
+	// ScopeFavorites lets an API key manage its owner's favorites. Other
+	// scopes are permissions, which a key can only get if its owner has them.
+	ScopeFavorites = "favorites"
+)
+
+// rolePermissions is the whole policy. Roles are checked against it on every
+// request, so changing it here takes effect without reissuing tokens.
+var rolePermissions = map[string][]string{
+	RoleUser:      {},
+	RoleModerator: {PermNewsModerate},
+	RoleAdmin:     {PermNewsModerate, PermSourcesManage, PermUsersAdmin},
+}
+
+func ValidRole(role string) bool {
+	_, ok := rolePermissions[role]
+	return ok
+}
+
+// Roles lists the known roles, least privileged first.
+func Roles() []string {
+	return []string{RoleUser, RoleModerator, RoleAdmin}
+}
+
+func Permissions(role string) []string {
+	return rolePermissions[role]
+}
+
+func Has(role, permission string) bool {
+	for _, p := range rolePermissions[role] {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// Scopes lists what an API key owned by a user with role may be granted.
+func Scopes(role string) []string {
+	return append([]string{ScopeFavorites}, rolePermissions[role]...)
+}
+
+func ValidScope(role, scope string) bool {
+	for _, s := range Scopes(role) {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,124 @@
+package revocation
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/redis/go-redis/v9"
+)
+
+// Store tracks access tokens that must stop working before they expire.
+// Entries live in Redis so every service validating tokens sees a logout
+// immediately.
+type Store struct {
+	redis *redis.Client
+}
+
+func New(redisClient *redis.Client) *Store {
+	return &Store{redis: redisClient}
+}
+
+func tokenKey(jti string) string {
+	return fmt.Sprintf("revoked:jti:%s", jti)
+}
+
+func userKey(userID uint) string {
+	return fmt.Sprintf("revoked:user:%d", userID)
+}
+
+func sessionKey(sid string) string {
+	return fmt.Sprintf("revoked:session:%s", sid)
+}
+
+// Revoke denylists a single token until it would have expired anyway.
+func (s *Store) Revoke(ctx context.Context, jti string, expiresAt time.Time) error {
+	ttl := time.Until(expiresAt)
+	if jti == "" || ttl <= 0 {
+		return nil
+	}
+	return s.redis.Set(ctx, tokenKey(jti), 1, ttl).Err()
+}
+
+// RevokeSession invalidates every token issued for one login session. ttl
+// should be the longest access token lifetime, as for RevokeUser.
+func (s *Store) RevokeSession(ctx context.Context, sid string, ttl time.Duration) error {
+	if sid == "" {
+		return nil
+	}
+	return s.redis.Set(ctx, sessionKey(sid), 1, ttl).Err()
+}
+
+// RevokeUser invalidates every token issued to the user up to now. ttl should
+// be the longest access token lifetime; older tokens have expired by then.
+func (s *Store) RevokeUser(ctx context.Context, userID uint, ttl time.Duration) error {
+	return s.RevokeUserBefore(ctx, userID, time.Now(), ttl)
+}
+
+// RevokeUserBefore invalidates the user's tokens issued before the given
+// time. The watermark has millisecond precision, like the iat auth-service
+// issues, so a token minted right after it keeps working.
+func (s *Store) RevokeUserBefore(ctx context.Context, userID uint, before time.Time, ttl time.Duration) error {
+	return s.redis.Set(ctx, userKey(userID), before.UnixMilli(), ttl).Err()
+}
+
+// legacyWatermark is the largest value read as a watermark in seconds, the
+// unit used before watermarks had millisecond precision; any time since
+// 1973 in milliseconds is larger.
+const legacyWatermark = 100_000_000_000
+
+// IssuedAt reads the iat claim with the fractional seconds auth-service
+// puts in it. jwt's own NumericDate parsing rounds to whole seconds.
+func IssuedAt(claims jwt.MapClaims) time.Time {
+	switch iat := claims["iat"].(type) {
+	case float64:
+		return time.UnixMilli(int64(math.Round(iat * 1000)))
+	case json.Number:
+		if value, err := iat.Float64(); err == nil {
+			return time.UnixMilli(int64(math.Round(value * 1000)))
+		}
+	}
+	return time.Time{}
+}
+
+// IsRevoked reports whether the token identified by jti, issued to userID at
+// issuedAt for session sid, has been revoked on its own, with its session or
+// by a per-user watermark.
+func (s *Store) IsRevoked(ctx context.Context, jti, sid string, userID uint, issuedAt time.Time) (bool, error) {
+	pipe := s.redis.Pipeline()
+	var denied, sessionDenied *redis.IntCmd
+	if jti != "" {
+		denied = pipe.Exists(ctx, tokenKey(jti))
+	}
+	if sid != "" {
+		sessionDenied = pipe.Exists(ctx, sessionKey(sid))
+	}
+	watermark := pipe.Get(ctx, userKey(userID))
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return false, err
+	}
+
+	if denied != nil && denied.Val() > 0 {
+		return true, nil
+	}
+	if sessionDenied != nil && sessionDenied.Val() > 0 {
+		return true, nil
+	}
+
+	if value, err := watermark.Result(); err == nil {
+		before, err := strconv.ParseInt(value, 10, 64)
+		if err == nil && before <= legacyWatermark {
+			// Whole seconds, so a token from that second counts as before it
+			before = (before + 1) * 1000
+		}
+		if err == nil && issuedAt.UnixMilli() < before {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
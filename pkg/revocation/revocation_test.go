@@ -0,0 +1,128 @@
+package revocation
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/redis/go-redis/v9"
+)
+
+func newStore(t *testing.T) (*Store, *miniredis.Miniredis) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	return New(redis.NewClient(&redis.Options{Addr: mr.Addr()})), mr
+}
+
+func TestIsRevokedWatermark(t *testing.T) {
+	ctx := context.Background()
+	store, _ := newStore(t)
+
+	watermark := time.Date(2026, 1, 2, 3, 4, 5, 500*int(time.Millisecond), time.UTC)
+	if err := store.RevokeUserBefore(ctx, 7, watermark, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		userID   uint
+		issuedAt time.Time
+		want     bool
+	}{
+		{"earlier second", 7, watermark.Add(-time.Second), true},
+		{"same second, before", 7, watermark.Add(-100 * time.Millisecond), true},
+		{"same second, after", 7, watermark.Add(100 * time.Millisecond), false},
+		{"later", 7, watermark.Add(time.Minute), false},
+		{"other user", 8, watermark.Add(-time.Second), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			revoked, err := store.IsRevoked(ctx, "", "", tt.userID, tt.issuedAt)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if revoked != tt.want {
+				t.Errorf("IsRevoked() = %v, want %v", revoked, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsRevokedLegacyWatermark(t *testing.T) {
+	ctx := context.Background()
+	store, mr := newStore(t)
+
+	// Written in whole seconds by an older release
+	watermark := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	mr.Set(userKey(7), strconv.FormatInt(watermark.Unix(), 10))
+
+	for _, issuedAt := range []time.Time{watermark.Add(-time.Second), watermark.Add(999 * time.Millisecond)} {
+		if revoked, _ := store.IsRevoked(ctx, "", "", 7, issuedAt); !revoked {
+			t.Errorf("token issued at %v survived", issuedAt)
+		}
+	}
+	if revoked, _ := store.IsRevoked(ctx, "", "", 7, watermark.Add(time.Second)); revoked {
+		t.Error("token issued after the watermark was revoked")
+	}
+}
+
+func TestIsRevokedTokenAndSession(t *testing.T) {
+	ctx := context.Background()
+	store, _ := newStore(t)
+	now := time.Now()
+
+	if err := store.Revoke(ctx, "jti-1", now.Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.RevokeSession(ctx, "sid-1", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		jti, sid string
+		want     bool
+	}{
+		{"jti-1", "sid-2", true},
+		{"jti-2", "sid-1", true},
+		{"jti-2", "sid-2", false},
+		{"", "", false},
+	}
+	for _, tt := range tests {
+		revoked, err := store.IsRevoked(ctx, tt.jti, tt.sid, 1, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if revoked != tt.want {
+			t.Errorf("IsRevoked(%q, %q) = %v, want %v", tt.jti, tt.sid, revoked, tt.want)
+		}
+	}
+}
+
+func TestIssuedAt(t *testing.T) {
+	want := time.UnixMilli(1767323045123)
+
+	tests := []struct {
+		name   string
+		claims jwt.MapClaims
+		want   time.Time
+	}{
+		{"fractional", jwt.MapClaims{"iat": 1767323045.123}, want},
+		{"json number", jwt.MapClaims{"iat": json.Number("1767323045.123")}, want},
+		{"whole seconds", jwt.MapClaims{"iat": float64(1767323045)}, time.Unix(1767323045, 0)},
+		{"missing", jwt.MapClaims{}, time.Time{}},
+		{"wrong type", jwt.MapClaims{"iat": "yesterday"}, time.Time{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IssuedAt(tt.claims); !got.Equal(tt.want) {
+				t.Errorf("IssuedAt() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
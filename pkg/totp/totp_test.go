@@ -0,0 +1,68 @@
+package totp
+
+import (
+	"testing"
+	"time"
+)
+
+// The SHA-1 seed from RFC 6238 appendix B, "12345678901234567890"
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestCode(t *testing.T) {
+	// RFC 6238 appendix B, truncated from 8 to 6 digits
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+
+	for _, tt := range tests {
+		got, err := Code(rfcSecret, Step(time.Unix(tt.unix, 0)))
+		if err != nil {
+			t.Fatalf("Code() error = %v", err)
+		}
+		if got != tt.want {
+			t.Errorf("Code(T=%d) = %s, want %s", tt.unix, got, tt.want)
+		}
+	}
+}
+
+func TestCodeInvalidSecret(t *testing.T) {
+	if _, err := Code("not base32!", 1); err == nil {
+		t.Error("Code() accepted an invalid secret")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+
+	tests := []struct {
+		name     string
+		code     string
+		skew     int
+		want     bool
+		wantStep int64
+	}{
+		{"current step", "050471", 1, true, Step(now)},
+		{"spaced", "050 471", 1, true, Step(now)},
+		{"previous step within skew", "081804", 1, true, Step(now) - 1},
+		{"previous step without skew", "081804", 0, false, 0},
+		{"wrong code", "000000", 1, false, 0},
+		{"too short", "05047", 1, false, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			step, ok := Validate(rfcSecret, tt.code, now, tt.skew)
+			if ok != tt.want || step != tt.wantStep {
+				t.Errorf("Validate() = %d, %v, want %d, %v", step, ok, tt.wantStep, tt.want)
+			}
+		})
+	}
+}
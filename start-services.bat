@@ -36,23 +36,24 @@ echo ========================================
 echo.
 
 echo Starting Auth Service (Port 8083)...
-start "Auth Service" cmd /k "echo Auth Service Starting... && go run ./cmd/auth-service/main.go"
+rem Development key for the signing keys at rest; only the auth service gets it
+start "Auth Service" cmd /k "(if not defined JWT_KEY_ENCRYPTION_KEY set JWT_KEY_ENCRYPTION_KEY=INydNRKZNh51oit1VGTzcEtEZ4r3drczh392uD3qz70=) && echo Auth Service Starting... && go run ./cmd/auth-service"
 timeout /t 2 /nobreak >nul
 
 echo Starting News API (Port 8081)...
-start "News API" cmd /k "echo News API Starting... && go run ./cmd/news-api/main.go"
+start "News API" cmd /k "echo News API Starting... && go run ./cmd/news-api"
 timeout /t 2 /nobreak >nul
 
 echo Starting News Scraper (Port 8082)...
-start "News Scraper" cmd /k "echo News Scraper Starting... && go run ./cmd/news-scraper/main.go"
+start "News Scraper" cmd /k "echo News Scraper Starting... && go run ./cmd/news-scraper"
 timeout /t 2 /nobreak >nul
 
 echo Starting API Gateway (Port 8080)...
-start "API Gateway" cmd /k "echo API Gateway Starting... && go run ./cmd/api-gateway/main.go"
+start "API Gateway" cmd /k "echo API Gateway Starting... && go run ./cmd/api-gateway"
 timeout /t 2 /nobreak >nul
 
 echo Starting Web Server (Port 3000)...
-start "Web Server" cmd /k "echo Web Server Starting... && go run ./cmd/web-server/main.go"
+start "Web Server" cmd /k "echo Web Server Starting... && go run ./cmd/web-server"
 
 echo.
 echo ========================================